@@ -0,0 +1,107 @@
+package FastAC
+
+import "fmt"
+
+// Adaptation sets how adaptive models learn. A model recomputes its
+// probabilities from its counts every update cycle; the cycle starts short,
+// grows after each update and stops at a maximum. When the total count passes
+// a limit, all counts are halved, so the limit sets how much history the
+// model remembers: a lower limit forgets faster and follows nonstationary
+// data better, a higher one estimates stationary data more precisely.
+//
+// A zero field keeps the model's default, so Adaptation{} is the original
+// FastAC behavior.
+type Adaptation struct {
+	// InitialCycle is the number of symbols before the first update after a
+	// reset. Default: 4 for bit models, (data_symbols + 6) / 2 for data
+	// models. It is capped by MaxCycle.
+	InitialCycle uint32
+
+	// Growth multiplies the cycle after each update, in sixteenths: 16 keeps
+	// it constant, 32 doubles it. Default: 20, that is 5/4. At most 64.
+	Growth uint32
+
+	// MaxCycle is the longest update cycle. Default: 64 for bit models,
+	// 8 * (data_symbols + 6) for data models. It is capped by CountLimit,
+	// and for data models by DM__MaxCount - data_symbols.
+	MaxCycle uint32
+
+	// CountLimit is the total count that triggers halving. Default and
+	// maximum: BM__MaxCount for bit models, DM__MaxCount for data models.
+	// Lower limits are raised to 16 for bit models and to 2 * data_symbols
+	// for data models.
+	CountLimit uint32
+}
+
+var (
+	// DefaultAdaptation keeps the original behavior.
+	DefaultAdaptation = Adaptation{}
+
+	// FastAdaptation forgets quickly, remembering about the last thousand
+	// symbols, and updates every 16 symbols: for telemetry and other data
+	// whose statistics drift.
+	FastAdaptation = Adaptation{Growth: 16, MaxCycle: 16, CountLimit: 1024}
+
+	// StationaryAdaptation keeps counts as long as precision allows and
+	// doubles the update cycle up to 1024 symbols, which saves time on data
+	// whose statistics do not change, such as archives.
+	StationaryAdaptation = Adaptation{Growth: 32, MaxCycle: 1024}
+)
+
+// adaptation is the resolved form kept by the models.
+type adaptation struct {
+	initial_cycle, growth, max_cycle, count_limit uint16
+}
+
+// resolve fills in the defaults of a model and checks the result. Count
+// limits are kept between min_count and max_count.
+func (ad Adaptation) resolve(op string, initial_cycle, max_cycle, min_count, max_count uint32) (adaptation, error) {
+	if ad.Growth != 0 && (ad.Growth < 16 || ad.Growth > 64) {
+		return adaptation{}, codecError(op, ErrInvalidAdaptation, fmt.Sprintf("growth %d/16", ad.Growth))
+	}
+	if ad.CountLimit > max_count {
+		return adaptation{}, codecError(op, ErrInvalidAdaptation, fmt.Sprintf("count limit %d", ad.CountLimit))
+	}
+	if ad.InitialCycle != 0 {
+		initial_cycle = ad.InitialCycle
+	}
+	growth := uint32(20)
+	if ad.Growth != 0 {
+		growth = ad.Growth
+	}
+	if ad.MaxCycle != 0 {
+		max_cycle = ad.MaxCycle
+	}
+	count_limit := max_count
+	if ad.CountLimit != 0 {
+		count_limit = ad.CountLimit
+	}
+	if count_limit < min_count {
+		count_limit = min_count
+	}
+	if max_cycle > count_limit {
+		max_cycle = count_limit
+	}
+	if initial_cycle > max_cycle {
+		initial_cycle = max_cycle
+	}
+	return adaptation{uint16(initial_cycle), uint16(growth), uint16(max_cycle), uint16(count_limit)}, nil
+}
+
+// nextCycle returns the update cycle after one of length cycle.
+func (ad *adaptation) nextCycle(cycle uint32) uint32 {
+	next := (uint32(ad.growth) * cycle) >> 4
+	if next == cycle && ad.growth > 16 {
+		next++ // short cycles would otherwise never grow
+	}
+	cycle = next
+	if cycle > uint32(ad.max_cycle) {
+		cycle = uint32(ad.max_cycle)
+	}
+	return cycle
+}
+
+// settings returns the exported form, with the defaults filled in.
+func (ad *adaptation) settings() Adaptation {
+	return Adaptation{uint32(ad.initial_cycle), uint32(ad.growth), uint32(ad.max_cycle), uint32(ad.count_limit)}
+}
@@ -0,0 +1,250 @@
+package FastAC
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var adaptationPresets = []struct {
+	name string
+	ad   Adaptation
+}{
+	{"Default", DefaultAdaptation},
+	{"Fast", FastAdaptation},
+	{"Stationary", StationaryAdaptation},
+}
+
+// nonstationaryBits draws bits with probability 0.95 of one value, switching
+// which value every period bits; period 0 never switches.
+func nonstationaryBits(n, period int) []uint32 {
+	src := initRandomBitSource()
+	src.SetSeed(18)
+	src.SetProbability0(0.95)
+	data := make([]uint32, n)
+	for k := range data {
+		if period > 0 && k%period == 0 {
+			src.SwitchProbabilities()
+		}
+		data[k] = uint32(src.Bit())
+	}
+	return data
+}
+
+// nonstationaryData draws symbols from a 64 symbol source whose probabilities
+// are shuffled every period symbols; period 0 never shuffles.
+func nonstationaryData(n, period int) []uint32 {
+	src := initRandomDataSource()
+	src.SetTruncatedGeometric(64, 3)
+	src.SetSeed(18)
+	data := make([]uint32, n)
+	for k := range data {
+		if period > 0 && k%period == 0 {
+			src.ShuffleProbabilities()
+		}
+		data[k] = src.Data()
+	}
+	return data
+}
+
+// bitModel is a bit model with a CostCodec method.
+type bitModel interface {
+	Bind(codec *ArithmeticCodec) Model
+}
+
+type resettableBitModel interface {
+	bitModel
+	Reset()
+}
+
+// costBits returns what coding data with model costs. setContext, when not
+// nil, selects the context of bit k before it is coded.
+func costBits(model bitModel, data []uint32, setContext func(k int)) float64 {
+	codec := NewCostCodec()
+	var encode func(bit uint32)
+	switch m := model.(type) {
+	case *AdaptiveBitModel:
+		encode = func(bit uint32) { codec.Encode_AdaptiveBitModel(bit, m) }
+	case *ShiftBitModel:
+		encode = func(bit uint32) { codec.Encode_ShiftBitModel(bit, m) }
+	case *HistoryBitModel:
+		encode = func(bit uint32) { codec.Encode_HistoryBitModel(bit, m) }
+	default:
+		panic(fmt.Sprintf("no cost for %T", model))
+	}
+	for k, bit := range data {
+		if setContext != nil {
+			setContext(k)
+		}
+		encode(bit)
+	}
+	return codec.Bits()
+}
+
+// checkBitCode codes data with model from its initial state, checks that the
+// code takes what costBits says, and decodes it back. The model is left
+// reset.
+func checkBitCode(t *testing.T, name string, model resettableBitModel, data []uint32, setContext func(k int)) {
+	t.Helper()
+	model.Reset()
+	codec := initArithmeticCodec(uint32(len(data)), nil)
+	codec.StartEncoder()
+	encoder := model.Bind(codec)
+	for k, bit := range data {
+		if setContext != nil {
+			setContext(k)
+		}
+		encoder.Encode(bit)
+	}
+	bits := 8 * float64(codec.StopEncoder())
+
+	model.Reset()
+	cost := costBits(model, data, setContext)
+	// The codec gives bit 1 the rounding remainder, so it can beat the cost
+	// slightly.
+	if bits < 0.999*cost || bits > 1.001*cost+32 {
+		t.Errorf("%s: coded %.0f bits, cost %.0f", name, bits, cost)
+	}
+
+	model.Reset()
+	codec.StartDecoder()
+	decoder := model.Bind(codec)
+	for k, bit := range data {
+		if setContext != nil {
+			setContext(k)
+		}
+		if got := decoder.Decode(); got != bit {
+			t.Fatalf("%s: bit %d decoded as %d, want %d", name, k, got, bit)
+		}
+	}
+	codec.StopDecoder()
+	model.Reset()
+}
+
+func adaptiveBitBits(ad Adaptation, data []uint32) float64 {
+	model, err := NewAdaptiveBitModelWith(ad)
+	mustSucceed(err)
+	return costBits(model, data, nil)
+}
+
+func adaptiveDataBits(ad Adaptation, data []uint32) float64 {
+	model, err := NewAdaptiveDataModelWith(64, ad)
+	mustSucceed(err)
+	codec := NewCostCodec()
+	for _, s := range data {
+		codec.Encode_AdaptiveDataModel(s, model)
+	}
+	return codec.Bits()
+}
+
+func TestAdaptation(t *testing.T) {
+	const n = 200000
+	drifting, stationary := nonstationaryBits(n, 1000), nonstationaryBits(n, 0)
+	if fast, slow := adaptiveBitBits(FastAdaptation, drifting), adaptiveBitBits(DefaultAdaptation, drifting); fast >= slow {
+		t.Errorf("switching bits: FastAdaptation used %.0f bits, default %.0f", fast, slow)
+	}
+	if fast, slow := adaptiveBitBits(FastAdaptation, stationary), adaptiveBitBits(StationaryAdaptation, stationary); slow >= fast {
+		t.Errorf("stationary bits: StationaryAdaptation used %.0f bits, fast %.0f", slow, fast)
+	}
+	drifting, stationary = nonstationaryData(n, 2000), nonstationaryData(n, 0)
+	if fast, slow := adaptiveDataBits(FastAdaptation, drifting), adaptiveDataBits(DefaultAdaptation, drifting); fast >= slow {
+		t.Errorf("shuffled data: FastAdaptation used %.0f bits, default %.0f", fast, slow)
+	}
+	if fast, slow := adaptiveDataBits(FastAdaptation, stationary), adaptiveDataBits(StationaryAdaptation, stationary); slow >= fast {
+		t.Errorf("stationary data: StationaryAdaptation used %.0f bits, fast %.0f", slow, fast)
+	}
+
+	// The defaults are the original constants.
+	if got, want := NewAdaptiveBitModel().Adaptation(), (Adaptation{4, 20, 64, BM__MaxCount}); got != want {
+		t.Errorf("AdaptiveBitModel.Adaptation() = %+v, want %+v", got, want)
+	}
+	if got, want := initAdaptiveDataModel(10).Adaptation(), (Adaptation{8, 20, 128, DM__MaxCount}); got != want {
+		t.Errorf("AdaptiveDataModel.Adaptation() = %+v, want %+v", got, want)
+	}
+	// Count limits stay above twice the alphabet.
+	if model, _ := NewAdaptiveDataModelWith(2048, FastAdaptation); model.Adaptation().CountLimit != 4096 {
+		t.Errorf("FastAdaptation count limit for 2048 symbols = %d, want 4096", model.Adaptation().CountLimit)
+	}
+
+	if _, err := NewAdaptiveBitModelWith(Adaptation{Growth: 8}); !errors.Is(err, ErrInvalidAdaptation) {
+		t.Errorf("NewAdaptiveBitModelWith(Growth 8) error = %v, want ErrInvalidAdaptation", err)
+	}
+	if _, err := NewAdaptiveDataModelWith(16, Adaptation{CountLimit: DM__MaxCount + 1}); !errors.Is(err, ErrInvalidAdaptation) {
+		t.Errorf("NewAdaptiveDataModelWith(CountLimit 2^15+1) error = %v, want ErrInvalidAdaptation", err)
+	}
+
+	// At the longest cycle accepted, halving still leaves every symbol a
+	// nonzero width.
+	wide, _ := NewAdaptiveDataModelWith(2048, Adaptation{MaxCycle: 1 << 15, Growth: 64})
+	if got, want := wide.Adaptation().MaxCycle, uint32(DM__MaxCount-2048); got != want {
+		t.Errorf("MaxCycle 2^15 for 2048 symbols resolved to %d, want %d", got, want)
+	}
+	// Skewed symbols keep many counts small and odd, which halving rounds up.
+	cost, rg := NewCostCodec(), initRandomGenerator(18)
+	for k := 0; k < 300000; k++ {
+		x := rg.Integer(2048)
+		cost.Encode_AdaptiveDataModel(x*x/2048, wide)
+		if wide.symbols_until_update != wide.update_cycle {
+			continue
+		}
+		for s := uint32(0); s < wide.last_symbol; s++ {
+			if wide.distribution[s+1] == wide.distribution[s] {
+				t.Fatalf("symbol %d has zero width after %d symbols", s, k+1)
+			}
+		}
+		if wide.distribution[wide.last_symbol] >= DM__MaxCount {
+			t.Fatalf("last symbol has zero width after %d symbols", k+1)
+		}
+	}
+
+	// Settings survive marshaling.
+	model, _ := NewAdaptiveDataModelWith(100, FastAdaptation)
+	state, _ := model.MarshalBinary()
+	restored := initAdaptiveDataModel(2)
+	if err := restored.UnmarshalBinary(state); err != nil || restored.Adaptation() != model.Adaptation() {
+		t.Errorf("restored Adaptation() = %+v (error %v), want %+v", restored.Adaptation(), err, model.Adaptation())
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Compression and speed of the presets on nonstationary sources - - - - -
+
+func BenchmarkAdaptation_Bits(b *testing.B) {
+	for _, period := range []int{0, 10000, 1000} {
+		data := nonstationaryBits(SimulTests>>2, period)
+		for _, preset := range adaptationPresets {
+			b.Run(fmt.Sprintf("switch%d/%s", period, preset.name), func(b *testing.B) {
+				codec := initArithmeticCodec(SimulTests, nil)
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					model, _ := NewAdaptiveBitModelWith(preset.ad)
+					codec.StartEncoder()
+					for _, bit := range data {
+						codec.Encode_AdaptiveBitModel(bit, model)
+					}
+					b.ReportMetric(8*float64(codec.StopEncoder())/float64(len(data)), "bits/symbol")
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkAdaptation_Data(b *testing.B) {
+	for _, period := range []int{0, 20000, 2000} {
+		data := nonstationaryData(SimulTests>>2, period)
+		for _, preset := range adaptationPresets {
+			b.Run(fmt.Sprintf("shuffle%d/%s", period, preset.name), func(b *testing.B) {
+				codec := initArithmeticCodec(SimulTests, nil)
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					model, _ := NewAdaptiveDataModelWith(64, preset.ad)
+					codec.StartEncoder()
+					for _, s := range data {
+						codec.Encode_AdaptiveDataModel(s, model)
+					}
+					b.ReportMetric(8*float64(codec.StopEncoder())/float64(len(data)), "bits/symbol")
+				}
+			})
+		}
+	}
+}
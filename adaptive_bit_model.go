@@ -3,24 +3,48 @@ package FastAC
 type AdaptiveBitModel struct {
 	update_cycle, bits_until_update    uint32
 	bit_0_prob, bit_0_count, bit_count uint32
+	adaptation
 }
 
-func initAdaptiveBitModel() *AdaptiveBitModel {
+// NewAdaptiveBitModel returns a bit model that starts with equal
+// probabilities and learns them from the coded bits.
+func NewAdaptiveBitModel() *AdaptiveBitModel {
+	a, err := NewAdaptiveBitModelWith(DefaultAdaptation)
+	mustSucceed(err)
+	return a
+}
+
+// NewAdaptiveBitModelWith returns a bit model that learns as set by ad.
+func NewAdaptiveBitModelWith(ad Adaptation) (*AdaptiveBitModel, error) {
 	a := new(AdaptiveBitModel)
+	var err error
+	if a.adaptation, err = ad.resolve("NewAdaptiveBitModel", 4, 64, 16, BM__MaxCount); err != nil {
+		return nil, err
+	}
 	a.reset()
-	return a
+	return a, nil
+}
+
+func initAdaptiveBitModel() *AdaptiveBitModel {
+	return NewAdaptiveBitModel()
 }
 
 func (a *AdaptiveBitModel) reset() {
 	a.bit_0_count = 1
 	a.bit_count = 2
 	a.bit_0_prob = 1 << (BM__LengthShift - 1)
-	a.update_cycle, a.bits_until_update = 4, 4
+	a.update_cycle = uint32(a.initial_cycle)
+	a.bits_until_update = a.update_cycle
+}
+
+// Adaptation returns the model's settings, with the defaults filled in.
+func (a *AdaptiveBitModel) Adaptation() Adaptation {
+	return a.settings()
 }
 
 func (a *AdaptiveBitModel) Update() {
 	a.bit_count += a.update_cycle
-	if a.bit_count > BM__MaxCount {
+	if a.bit_count > uint32(a.count_limit) {
 		a.bit_count = (a.bit_count + 1) >> 1
 		a.bit_0_count = (a.bit_0_count + 1) >> 1
 		if a.bit_0_count == a.bit_count {
@@ -31,9 +55,6 @@ func (a *AdaptiveBitModel) Update() {
 	scale := uint32(0x80000000 / a.bit_count)
 	a.bit_0_prob = (a.bit_0_count * scale) >> (31 - BM__LengthShift)
 
-	a.update_cycle = (5 * a.update_cycle) >> 2
-	if a.update_cycle > 64 {
-		a.update_cycle = 64
-	}
+	a.update_cycle = a.nextCycle(a.update_cycle)
 	a.bits_until_update = a.update_cycle
 }
@@ -1,23 +1,67 @@
 package FastAC
 
+import "fmt"
+
 type AdaptiveDataModel struct {
 	distribution, symbol_count, decoder_table []uint32
 
 	total_count, update_cycle, symbols_until_update uint32
 
 	data_symbols, last_symbol, table_size, table_shift uint32
+
+	requested Adaptation // resolved again for each alphabet
+	adaptation
 }
 
-func initAdaptiveDataModel(number_of_symbols uint32) *AdaptiveDataModel {
+// NewAdaptiveDataModel returns a model for number_of_symbols symbols (2 to
+// 2048) that starts with equal probabilities and learns them from the data.
+func NewAdaptiveDataModel(number_of_symbols uint32) (*AdaptiveDataModel, error) {
 	model := new(AdaptiveDataModel)
-	model.SetAlphabet(number_of_symbols)
+	if err := model.TrySetAlphabet(number_of_symbols); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// NewAdaptiveDataModelWith returns a model for number_of_symbols symbols that
+// learns as set by ad.
+func NewAdaptiveDataModelWith(number_of_symbols uint32, ad Adaptation) (*AdaptiveDataModel, error) {
+	model := &AdaptiveDataModel{requested: ad}
+	if err := model.TrySetAlphabet(number_of_symbols); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func initAdaptiveDataModel(number_of_symbols uint32) *AdaptiveDataModel {
+	model, err := NewAdaptiveDataModel(number_of_symbols)
+	mustSucceed(err)
 	return model
 }
 
 func (a *AdaptiveDataModel) SetAlphabet(number_of_symbols uint32) {
+	mustSucceed(a.TrySetAlphabet(number_of_symbols))
+}
+
+func (a *AdaptiveDataModel) TrySetAlphabet(number_of_symbols uint32) error {
 	if number_of_symbols < 2 || number_of_symbols > (1<<11) {
-		AC_Error("invalid number of data symbols")
+		return codecError("SetAlphabet", ErrInvalidAlphabet, fmt.Sprint(number_of_symbols))
 	}
+	ad, err := a.requested.resolve("SetAlphabet", (number_of_symbols+6)>>1, (number_of_symbols+6)<<3, 2*number_of_symbols, DM__MaxCount)
+	if err != nil {
+		return err
+	}
+	// The total may pass the count limit by a whole cycle before it is
+	// halved, and halving rounds each count up, so repeated halvings settle
+	// at up to max_cycle + data_symbols. Above DM__MaxCount that would round
+	// the width of a count of 1 down to zero.
+	if cycle_limit := DM__MaxCount - number_of_symbols; uint32(ad.max_cycle) > cycle_limit {
+		ad.max_cycle = uint16(cycle_limit)
+		if ad.initial_cycle > ad.max_cycle {
+			ad.initial_cycle = ad.max_cycle
+		}
+	}
+	a.adaptation = ad
 
 	if a.data_symbols != number_of_symbols {
 		a.data_symbols = number_of_symbols
@@ -41,11 +85,12 @@ func (a *AdaptiveDataModel) SetAlphabet(number_of_symbols uint32) {
 		a.symbol_count = a.distribution[a.data_symbols:]
 	}
 	a.Reset()
+	return nil
 }
 
 func (a *AdaptiveDataModel) Update(from_encoder bool) {
 	a.total_count += a.update_cycle
-	if a.total_count > DM__MaxCount {
+	if a.total_count > uint32(a.count_limit) {
 		a.total_count = 0
 		for n := uint32(0); n < a.data_symbols; n++ {
 			a.symbol_count[n] = (a.symbol_count[n] + 1) >> 1
@@ -78,11 +123,7 @@ func (a *AdaptiveDataModel) Update(from_encoder bool) {
 		}
 	}
 
-	a.update_cycle = (5 * a.update_cycle) >> 2
-	max_cycle := uint32((a.data_symbols + 6) << 3)
-	if a.update_cycle > max_cycle {
-		a.update_cycle = max_cycle
-	}
+	a.update_cycle = a.nextCycle(a.update_cycle)
 	a.symbols_until_update = a.update_cycle
 }
 
@@ -97,5 +138,10 @@ func (a *AdaptiveDataModel) Reset() {
 		a.symbol_count[k] = 1
 	}
 	a.Update(false)
-	a.symbols_until_update, a.update_cycle = (a.data_symbols+6)>>1, (a.data_symbols+6)>>1
+	a.symbols_until_update, a.update_cycle = uint32(a.initial_cycle), uint32(a.initial_cycle)
+}
+
+// Adaptation returns the model's settings, with the defaults filled in.
+func (a *AdaptiveDataModel) Adaptation() Adaptation {
+	return a.settings()
 }
@@ -0,0 +1,219 @@
+package FastAC
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// AlphabetPartition splits the 32-bit integers into groups, following Amir
+// Said's alphabet partitioning: the group of a value is coded with an
+// adaptive model over a small alphabet, and its offset within the group is
+// coded with PutBits, assuming values in a group are about equally likely.
+//
+// Values are first split by magnitude into 33 classes, 0 and [2^(k-1), 2^k)
+// for k = 1..32. Entry k of the partition is the number of leading mantissa
+// bits, after the top bit, that class k adds to the group: class k is divided
+// into 2^p[k] groups and the remaining k - 1 - p[k] bits are the offset. The
+// all-zero partition is an Elias-gamma-like code with 33 groups.
+//
+// A partition is 33 bytes and can be stored with the code it was used for.
+type AlphabetPartition [33]uint8
+
+// Groups returns the number of groups, the alphabet of the group model.
+func (p *AlphabetPartition) Groups() uint32 {
+	groups := uint32(0)
+	for _, m := range p {
+		groups += 1 << m
+	}
+	return groups
+}
+
+func (p *AlphabetPartition) validate(op string) error {
+	for k, m := range p {
+		if k == 0 && m != 0 || k > 0 && int(m) > k-1 {
+			return codecError(op, ErrInvalidAlphabet, fmt.Sprintf("class %d split into %d bits", k, m))
+		}
+	}
+	if g := p.Groups(); g > (1 << 11) {
+		return codecError(op, ErrInvalidAlphabet, fmt.Sprintf("%d groups", g))
+	}
+	return nil
+}
+
+// Depth of the sample statistics kept for each magnitude class; enough to
+// split a class into all 2^11 groups an AdaptiveDataModel allows.
+const partitionStatBits = 11
+
+// DesignAlphabetPartition chooses a partition for values like samples with at
+// most max_groups groups (33 to 2048). Starting from the all-zero partition,
+// it repeatedly splits the groups of the class where that saves the most
+// bits per added group, estimated from the samples, while the saving beats
+// the cost of learning the new groups' probabilities.
+func DesignAlphabetPartition(samples []uint32, max_groups uint32) (AlphabetPartition, error) {
+	var p AlphabetPartition
+	if max_groups < 33 || max_groups > (1<<11) {
+		return p, codecError("DesignAlphabetPartition", ErrInvalidAlphabet, fmt.Sprint(max_groups))
+	}
+
+	// count[k][t] is the number of samples in class k whose top mantissa bits
+	// are t, with depth[k] bits kept.
+	var count [33][]uint32
+	var depth [33]uint
+	for k := range count {
+		depth[k] = uint(k - 1)
+		if k == 0 {
+			depth[k] = 0
+		} else if depth[k] > partitionStatBits {
+			depth[k] = partitionStatBits
+		}
+		count[k] = make([]uint32, 1<<depth[k])
+	}
+	for _, v := range samples {
+		k := bits.Len32(v)
+		t := uint32(0)
+		if k > 0 {
+			t = (v - 1<<(k-1)) >> (uint(k-1) - depth[k])
+		}
+		count[k][t]++
+	}
+
+	// Adaptive models need some bits to learn each probability.
+	penalty := 1 + 0.5*math.Log2(float64(len(samples))+1)
+
+	groups := p.Groups()
+	for {
+		best, best_gain := -1, 0.0
+		for k := 1; k < len(p); k++ {
+			m := uint(p[k])
+			added := uint32(1) << m
+			if m >= depth[k] || groups+added > max_groups {
+				continue
+			}
+			gain := splitGain(count[k], depth[k], m) - penalty*float64(added)
+			if gain > 0 && (best < 0 || gain/float64(added) > best_gain) {
+				best, best_gain = k, gain/float64(added)
+			}
+		}
+		if best < 0 {
+			return p, nil
+		}
+		groups += 1 << p[best]
+		p[best]++
+	}
+}
+
+// splitGain estimates the bits saved by splitting each group of a class with
+// m split bits in two: one offset bit fewer for every value, against the
+// entropy of the choice between the halves.
+func splitGain(count []uint32, depth, m uint) float64 {
+	half := 1 << (depth - m - 1)
+	gain := 0.0
+	for g := 0; g < len(count); g += 2 * half {
+		n0, n1 := 0.0, 0.0
+		for _, c := range count[g : g+half] {
+			n0 += float64(c)
+		}
+		for _, c := range count[g+half : g+2*half] {
+			n1 += float64(c)
+		}
+		n := n0 + n1
+		gain += n
+		if n0 > 0 {
+			gain += n0 * math.Log2(n0/n)
+		}
+		if n1 > 0 {
+			gain += n1 * math.Log2(n1/n)
+		}
+	}
+	return gain
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// AlphabetPartitionCoder codes 32-bit integers with an ArithmeticCodec using
+// an AlphabetPartition. Its memory is bounded by the number of groups,
+// whatever the range of the values.
+type AlphabetPartitionCoder struct {
+	partition   AlphabetPartition
+	class_first [33]uint32 // first group of each class
+	group_class []uint8
+	group_model *AdaptiveDataModel
+}
+
+// NewAlphabetPartitionCoder returns a coder for partition, which must split
+// no class into more groups than its values and have at most 2048 groups.
+func NewAlphabetPartitionCoder(partition AlphabetPartition) (*AlphabetPartitionCoder, error) {
+	if err := partition.validate("NewAlphabetPartitionCoder"); err != nil {
+		return nil, err
+	}
+	model, err := NewAdaptiveDataModel(partition.Groups())
+	if err != nil {
+		return nil, err
+	}
+	c := &AlphabetPartitionCoder{partition: partition, group_model: model}
+	c.group_class = make([]uint8, 0, partition.Groups())
+	for k, m := range partition {
+		c.class_first[k] = uint32(len(c.group_class))
+		for g := 0; g < 1<<m; g++ {
+			c.group_class = append(c.group_class, uint8(k))
+		}
+	}
+	return c, nil
+}
+
+// Partition returns the partition the coder was built with.
+func (c *AlphabetPartitionCoder) Partition() AlphabetPartition {
+	return c.partition
+}
+
+// Reset restarts the group model with equal probabilities.
+func (c *AlphabetPartitionCoder) Reset() {
+	c.group_model.Reset()
+}
+
+func (c *AlphabetPartitionCoder) Encode(codec *ArithmeticCodec, value uint32) {
+	k := bits.Len32(value)
+	if k == 0 {
+		codec.Encode_AdaptiveDataModel(c.class_first[0], c.group_model)
+		return
+	}
+	offset_bits := uint32(k-1) - uint32(c.partition[k])
+	mantissa := value - 1<<(k-1)
+	codec.Encode_AdaptiveDataModel(c.class_first[k]+mantissa>>offset_bits, c.group_model)
+	putLongBits(codec, mantissa&(1<<offset_bits-1), offset_bits)
+}
+
+func (c *AlphabetPartitionCoder) Decode(codec *ArithmeticCodec) uint32 {
+	g := codec.Decode_AdaptiveDataModel(c.group_model)
+	k := c.group_class[g]
+	if k == 0 {
+		return 0
+	}
+	offset_bits := uint32(k-1) - uint32(c.partition[k])
+	mantissa := (g-c.class_first[k])<<offset_bits | getLongBits(codec, offset_bits)
+	return 1<<(k-1) + mantissa
+}
+
+// PutBits codes at most 20 bits at a time; offsets can have up to 31.
+func putLongBits(codec *ArithmeticCodec, data, bits uint32) {
+	if bits > 16 {
+		codec.PutBits(data>>16, bits-16)
+		data, bits = data&0xFFFF, 16
+	}
+	if bits > 0 {
+		codec.PutBits(data, bits)
+	}
+}
+
+func getLongBits(codec *ArithmeticCodec, bits uint32) uint32 {
+	data := uint32(0)
+	if bits > 16 {
+		data = codec.GetBits(bits-16) << 16
+		bits = 16
+	}
+	if bits > 0 {
+		data |= codec.GetBits(bits)
+	}
+	return data
+}
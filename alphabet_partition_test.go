@@ -0,0 +1,75 @@
+package FastAC
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestAlphabetPartitionCoder_RoundTrip(t *testing.T) {
+	const n = 200000
+	rg := initRandomGenerator(2010)
+	data := make([]uint32, n)
+	histogram := make(map[uint32]float64)
+	for k := range data {
+		// Mostly exponential with mean 3000, plus occasional full-range words.
+		if rg.Integer(50) == 0 {
+			data[k] = rg.Word()
+		} else {
+			data[k] = uint32(-3000 * math.Log(1-rg.Uniform()))
+		}
+		histogram[data[k]]++
+	}
+	entropy := 0.0
+	for _, c := range histogram {
+		entropy -= c * math.Log2(c/n)
+	}
+
+	designed, err := DesignAlphabetPartition(data[:n/2], 256)
+	if err != nil {
+		t.Fatalf("DesignAlphabetPartition() error = %v", err)
+	}
+	if designed.Groups() > 256 {
+		t.Errorf("designed partition has %d groups, want at most 256", designed.Groups())
+	}
+
+	code_bits := make(map[uint32]float64)
+	for _, partition := range []AlphabetPartition{{}, designed} {
+		coder, err := NewAlphabetPartitionCoder(partition)
+		if err != nil {
+			t.Fatalf("NewAlphabetPartitionCoder() error = %v", err)
+		}
+		codec := initArithmeticCodec(8*n, nil)
+		codec.StartEncoder()
+		for _, v := range data {
+			coder.Encode(codec, v)
+		}
+		code_bits[partition.Groups()] = 8 * float64(codec.StopEncoder())
+
+		coder.Reset()
+		codec.StartDecoder()
+		for k, v := range data {
+			if d := coder.Decode(codec); d != v {
+				t.Fatalf("%d groups: value %d decoded as %d, want %d", partition.Groups(), k, d, v)
+			}
+		}
+		codec.StopDecoder()
+	}
+
+	// The empirical entropy is a lower bound that no coder of single values
+	// can reach with this many distinct values; a good partition gets close.
+	t.Logf("entropy %.0f bits, %d groups %.0f bits, 33 groups %.0f bits", entropy, designed.Groups(), code_bits[designed.Groups()], code_bits[33])
+	if code_bits[designed.Groups()] > 1.05*entropy {
+		t.Errorf("designed partition used %.0f bits, entropy is %.0f", code_bits[designed.Groups()], entropy)
+	}
+	if code_bits[designed.Groups()] >= code_bits[33] {
+		t.Errorf("designed partition used %.0f bits, the default %.0f", code_bits[designed.Groups()], code_bits[33])
+	}
+
+	if _, err := NewAlphabetPartitionCoder(AlphabetPartition{1: 1}); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("NewAlphabetPartitionCoder(class 1 split) error = %v, want ErrInvalidAlphabet", err)
+	}
+	if _, err := DesignAlphabetPartition(data, 32); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("DesignAlphabetPartition(32 groups) error = %v, want ErrInvalidAlphabet", err)
+	}
+}
@@ -0,0 +1,235 @@
+package FastAC
+
+import "fmt"
+
+const apmRate = 7 // an APM bucket moves 1/128 of the way to each bit
+
+// apm refines a probability in a context: it maps the stretched probability
+// onto 33 buckets per context and interpolates between the two around it.
+// Buckets start as the identity and learn the probability of a 1 actually
+// seen for inputs near them; only the nearer of the two is updated.
+type apm struct {
+	t     []uint16 // 16-bit probabilities of a 1
+	index uint32   // bucket to update
+}
+
+func newAPM(contexts uint32) apm {
+	a := apm{t: make([]uint16, contexts*33)}
+	a.reset()
+	return a
+}
+
+func (a *apm) reset() {
+	for k := range a.t {
+		a.t[k] = uint16(squash(int32(k%33-16)*128) * 16)
+	}
+	a.index = 0
+}
+
+// refine returns the refined probability of pr, over MX__MaxProb, in context.
+func (a *apm) refine(pr, context uint32) uint32 {
+	p := a.refine16(pr, context) >> (SM__LengthShift - MX__ProbBits)
+	if p == 0 {
+		return 1
+	}
+	if p >= MX__MaxProb {
+		return MX__MaxProb - 1
+	}
+	return p
+}
+
+// refine16 is refine with the interpolated probability kept over SM__MaxProb,
+// from 1 to SM__MaxProb - 1.
+func (a *apm) refine16(pr, context uint32) uint32 {
+	s := stretch(pr) + 2048
+	w := uint32(s & 127)
+	k := context*33 + uint32(s>>7)
+	a.index = k + w>>6
+	p := (uint32(a.t[k])*(128-w) + uint32(a.t[k+1])*w) >> 7
+	if p == 0 {
+		return 1
+	}
+	if p >= SM__MaxProb {
+		return SM__MaxProb - 1
+	}
+	return p
+}
+
+// blend averages pr with its refinement, weighing the refinement three times
+// as much: the buckets learn slowly, and pr still holds what they miss.
+func (a *apm) blend(pr, context uint32) uint32 {
+	return (pr + 3*a.refine(pr, context) + 2) >> 2
+}
+
+func (a *apm) update(bit uint32) {
+	t := int32(a.t[a.index])
+	t += (int32(bit)*65535 - t) >> apmRate
+	a.t[a.index] = uint16(t)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// APM, an adaptive probability map, refines the probability given by another
+// model in a small context, a stage also known as secondary symbol
+// estimation. For each context it learns, from the bits coded, what
+// probability a 1 actually had whenever the model predicted about p. It
+// corrects a model that is biased, slow to adapt, or blind to the context,
+// and costs little where the model is right.
+//
+// Wrap attaches an APM to a BitPredictor; AdaptiveBitModel.Predictor makes
+// one of an AdaptiveBitModel. Encode_AdaptiveBitModelAPM and its decoder
+// refine an AdaptiveBitModel directly, at its full precision.
+type APM struct {
+	apm
+	contexts, context uint32
+}
+
+// NewAPM returns a map for contexts contexts, from 1 to 2^16, with context 0
+// selected.
+func NewAPM(contexts uint32) (*APM, error) {
+	if contexts < 1 || contexts > 1<<16 {
+		return nil, codecError("NewAPM", ErrInvalidContext, fmt.Sprintf("%d contexts", contexts))
+	}
+	return &APM{apm: newAPM(contexts), contexts: contexts}, nil
+}
+
+func initAPM(contexts uint32) *APM {
+	a, err := NewAPM(contexts)
+	mustSucceed(err)
+	return a
+}
+
+func (a *APM) SetContext(context uint32) {
+	mustSucceed(a.TrySetContext(context))
+}
+
+// TrySetContext selects the context of the next Refine.
+func (a *APM) TrySetContext(context uint32) error {
+	if context >= a.contexts {
+		return codecError("SetContext", ErrInvalidContext, fmt.Sprintf("context %d of %d", context, a.contexts))
+	}
+	a.context = context
+	return nil
+}
+
+// Reset forgets what the map learned.
+func (a *APM) Reset() {
+	a.reset()
+	a.context = 0
+}
+
+// Refine returns the refined probability of a 1, over MX__MaxProb, for a
+// model predicting p, from 1 to MX__MaxProb - 1, in the selected context.
+// Update must follow with the bit coded.
+func (a *APM) Refine(p uint32) uint32 {
+	return a.blend(p, a.context)
+}
+
+// Update trains the map on bit, the bit predicted by the last Refine.
+func (a *APM) Update(bit uint32) {
+	a.update(bit)
+}
+
+// Wrap returns a predictor that refines the predictions of P with the map.
+// Its Update trains the map and then updates P.
+func (a *APM) Wrap(P BitPredictor) BitPredictor {
+	return refinedPredictor{a, P}
+}
+
+type refinedPredictor struct {
+	apm   *APM
+	input BitPredictor
+}
+
+func (r refinedPredictor) P() uint32 {
+	return r.apm.Refine(r.input.P())
+}
+
+func (r refinedPredictor) Update(bit uint32) {
+	r.apm.Update(bit)
+	r.input.Update(bit)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// refineBit0 returns the probability of a 0, over SM__MaxProb, that the map
+// gives for M in the selected context. The map sees M's prediction rounded to
+// 12 bits, but the blend keeps it, and the refinement, at 16 bits, so the
+// result is as fine as the model's own BM__LengthShift bits.
+func (a *APM) refineBit0(M *AdaptiveBitModel) uint32 {
+	p1 := BM__MaxCount - M.bit_0_prob
+	pr := p1 >> (BM__LengthShift - MX__ProbBits)
+	if pr == 0 {
+		pr = 1
+	}
+	p := (p1<<(SM__LengthShift-BM__LengthShift) + 3*a.refine16(pr, a.context) + 2) >> 2
+	return SM__MaxProb - p
+}
+
+// Encode_AdaptiveBitModelAPM codes bit with the probability of M refined by
+// A in its selected context, then updates both. It fits the coding loops of
+// Encode_AdaptiveBitModel, which it replaces for a refined model, and loses
+// no precision of the model's probability.
+func (a *ArithmeticCodec) Encode_AdaptiveBitModelAPM(bit uint32, M *AdaptiveBitModel, A *APM) {
+	a.encodeBit(bit, A.refineBit0(M))
+	A.Update(bit)
+	adaptiveBitPredictor{M}.Update(bit)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_AdaptiveBitModelAPM(M *AdaptiveBitModel, A *APM) uint32 {
+	bit := a.decodeBit(A.refineBit0(M))
+	A.Update(bit)
+	adaptiveBitPredictor{M}.Update(bit)
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// Predictor returns the model as a BitPredictor, to be refined by an APM or
+// mixed with other predictors. Coding through it updates the model exactly
+// as Encode_AdaptiveBitModel does, with the probability rounded to 12 bits;
+// Encode_AdaptiveBitModelAPM refines it without rounding.
+func (a *AdaptiveBitModel) Predictor() BitPredictor {
+	return adaptiveBitPredictor{a}
+}
+
+type adaptiveBitPredictor struct {
+	model *AdaptiveBitModel
+}
+
+func (b adaptiveBitPredictor) P() uint32 {
+	if p := (BM__MaxCount - b.model.bit_0_prob) >> (BM__LengthShift - MX__ProbBits); p != 0 {
+		return p
+	}
+	return 1
+}
+
+func (b adaptiveBitPredictor) Update(bit uint32) {
+	M := b.model
+	if bit == 0 {
+		M.bit_0_count++
+	}
+	M.bits_until_update--
+	if M.bits_until_update == 0 {
+		M.Update()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundBitPredictor struct {
+	codec     *ArithmeticCodec
+	predictor BitPredictor
+}
+
+func (b boundBitPredictor) Encode(symbol uint32) { b.codec.Encode_BitPredictor(symbol, b.predictor) }
+func (b boundBitPredictor) Decode() uint32       { return b.codec.Decode_BitPredictor(b.predictor) }
+
+// BindPredictor returns P attached to codec, so that a coding loop written
+// against Model can switch to a refined or mixed prediction, for instance
+// from M.Bind(codec) to BindPredictor(codec, apm.Wrap(M.Predictor())).
+func BindPredictor(codec *ArithmeticCodec, P BitPredictor) Model {
+	return boundBitPredictor{codec, P}
+}
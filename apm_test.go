@@ -0,0 +1,121 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPM(t *testing.T) {
+	const n = 200000
+
+	// Bits that depend on the bit before them, which AdaptiveBitModel does
+	// not see but the APM gets as its context.
+	src := initRandomBitSource()
+	src.SetSeed(22)
+	data := make([]uint32, n)
+	for k := 1; k < n; k++ {
+		src.SetProbability0([]float64{0.9, 0.2}[data[k-1]])
+		data[k] = uint32(src.Bit())
+	}
+
+	model, refine := initAdaptiveBitModel(), initAPM(2)
+	codec := initArithmeticCodec(n, nil)
+	codec.StartEncoder()
+	encoder := BindPredictor(codec, refine.Wrap(model.Predictor()))
+	for k, bit := range data {
+		if k > 0 {
+			refine.SetContext(data[k-1])
+		}
+		encoder.Encode(bit)
+	}
+	refined := 8 * codec.StopEncoder()
+
+	model, refine = initAdaptiveBitModel(), initAPM(2)
+	codec.StartDecoder()
+	decoder := BindPredictor(codec, refine.Wrap(model.Predictor()))
+	for k, bit := range data {
+		if k > 0 {
+			refine.SetContext(data[k-1])
+		}
+		if got := decoder.Decode(); got != bit {
+			t.Fatalf("bit %d decoded as %d, want %d", k, got, bit)
+		}
+	}
+	codec.StopDecoder()
+
+	// The entropy of the source is about 0.55 bits per bit, against 0.92 for
+	// a model of the bits alone.
+	if plain := adaptiveBitBits(DefaultAdaptation, data); float64(refined) > 0.7*plain {
+		t.Errorf("context-dependent bits: refined model used %d bits, AdaptiveBitModel %.0f", refined, plain)
+	}
+
+	// The codec methods refine the model in the same coding loop as
+	// Encode_AdaptiveBitModel, without rounding its probability to 12 bits.
+	model, refine = initAdaptiveBitModel(), initAPM(2)
+	codec.StartEncoder()
+	for k, bit := range data {
+		if k > 0 {
+			refine.SetContext(data[k-1])
+		}
+		codec.Encode_AdaptiveBitModelAPM(bit, model, refine)
+	}
+	direct := 8 * codec.StopEncoder()
+	model, refine = initAdaptiveBitModel(), initAPM(2)
+	codec.StartDecoder()
+	for k, bit := range data {
+		if k > 0 {
+			refine.SetContext(data[k-1])
+		}
+		if got := codec.Decode_AdaptiveBitModelAPM(model, refine); got != bit {
+			t.Fatalf("Decode_AdaptiveBitModelAPM: bit %d decoded as %d, want %d", k, got, bit)
+		}
+	}
+	codec.StopDecoder()
+	if float64(direct) > 1.01*float64(refined) {
+		t.Errorf("context-dependent bits: Encode_AdaptiveBitModelAPM used %d bits, the wrapped predictor %d", direct, refined)
+	}
+
+	// On long runs of one bit the model's probability passes 4095/4096,
+	// which only the full-precision path can use.
+	zeros := make([]uint32, n)
+	full, rounded := NewCostCodec(), NewCostCodec()
+	full_model, full_map := initAdaptiveBitModel(), initAPM(1)
+	wrapped := initAPM(1).Wrap(initAdaptiveBitModel().Predictor())
+	for _, bit := range zeros {
+		full.Encode_AdaptiveBitModelAPM(bit, full_model, full_map)
+		rounded.Encode_BitPredictor(bit, wrapped)
+	}
+	t.Logf("%d zeros: %.0f bits at full precision, %.0f rounded; context-dependent bits: %d and %d", n, full.Bits(), rounded.Bits(), direct, refined)
+	if full.Bits() >= rounded.Bits() {
+		t.Errorf("%d zeros: Encode_AdaptiveBitModelAPM cost %.0f bits, the wrapped predictor %.0f", n, full.Bits(), rounded.Bits())
+	}
+
+	// Where the model is right the map costs little.
+	stationary := nonstationaryBits(n, 0)
+	cost := NewCostCodec()
+	wrapped = initAPM(1).Wrap(initAdaptiveBitModel().Predictor())
+	for _, bit := range stationary {
+		cost.Encode_BitPredictor(bit, wrapped)
+	}
+	if plain := adaptiveBitBits(DefaultAdaptation, stationary); cost.Bits() > 1.02*plain {
+		t.Errorf("stationary bits: refined model used %.0f bits, AdaptiveBitModel %.0f", cost.Bits(), plain)
+	}
+
+	// The predictor of an AdaptiveBitModel updates it as the codec does.
+	coded, predicted := initAdaptiveBitModel(), initAdaptiveBitModel()
+	counted := NewCostCodec()
+	for _, bit := range stationary[:1000] {
+		counted.Encode_AdaptiveBitModel(bit, coded)
+		predicted.Predictor().Update(bit)
+	}
+	if *coded != *predicted {
+		t.Errorf("model state after Predictor().Update = %+v, want %+v", *predicted, *coded)
+	}
+
+	if _, err := NewAPM(0); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("NewAPM(0) error = %v, want ErrInvalidContext", err)
+	}
+	if err := refine.TrySetContext(2); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("TrySetContext(2) error = %v, want ErrInvalidContext", err)
+	}
+}
@@ -2,6 +2,7 @@ package FastAC
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -17,11 +18,15 @@ const (
 )
 
 type ArithmeticCodec struct {
-	// The ac pointer is really there to just count backwards... so we can just use code_buffer
-	code_buffer, new_buffer, ac_pointer []byte
-	base, value, length                 uint32
-	buffer_size                         uint32
-	mode                                Mode
+	codeBuffer
+	// While encoding, ac_pointer is the prefix of code_buffer written so far, so
+	// carries can walk backwards from its end. While decoding it is the suffix of
+	// code_buffer starting at the last byte read.
+	ac_pointer          []byte
+	base, value, length uint32
+	sink                *codeSink // set by StartStreamEncoder
+	overflow            bool      // the encoder ran past buffer_size
+	corrupt             bool      // the decoder left its interval
 }
 
 func AC_Error(message string) {
@@ -31,41 +36,36 @@ func AC_Error(message string) {
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 // - - Static functions  - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func initArithmeticCodec(max_code_bytes uint32, user_buffer []byte) *ArithmeticCodec {
+// NewArithmeticCodec returns a codec that codes into user_buffer, or into a
+// buffer of max_code_bytes it allocates itself when user_buffer is nil. A zero
+// size with a nil buffer leaves the buffer to be set later with SetBuffer.
+func NewArithmeticCodec(max_code_bytes uint32, user_buffer []byte) (*ArithmeticCodec, error) {
 	codec := new(ArithmeticCodec)
-	codec.SetBuffer(max_code_bytes, user_buffer)
-	return codec
-}
-
-func (a *ArithmeticCodec) SetBuffer(max_code_bytes uint32, user_buffer []byte) {
-	if (max_code_bytes < 16 || max_code_bytes > 0x1000000) && user_buffer != nil {
-		AC_Error("invalid codec buffer size: " + fmt.Sprint(max_code_bytes))
-	}
-	if a.mode != Undefined {
-		AC_Error("cannot set buffer while encoding or decoding")
-	}
-
-	if user_buffer != nil {
-		a.buffer_size = max_code_bytes
-		a.code_buffer = user_buffer
-		return
-	}
-
-	if max_code_bytes <= a.buffer_size {
-		return
+	if err := codec.TrySetBuffer(max_code_bytes, user_buffer); err != nil {
+		return nil, err
 	}
+	return codec, nil
+}
 
-	a.buffer_size = max_code_bytes
-	a.new_buffer = make([]byte, a.buffer_size+16)
-	a.code_buffer = a.new_buffer
+func initArithmeticCodec(max_code_bytes uint32, user_buffer []byte) *ArithmeticCodec {
+	codec, err := NewArithmeticCodec(max_code_bytes, user_buffer)
+	mustSucceed(err)
+	return codec
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 // - - Coding implementations  - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) PropagateCarry() {
-	var p uint32
-	for p := len(a.ac_pointer) - 1; a.ac_pointer[p] == 0xFF && p != 0; p-- {
+	if a.sink != nil {
+		a.sink.carry()
+		return
+	}
+	if a.overflow {
+		return
+	}
+	p := len(a.ac_pointer) - 1
+	for ; a.ac_pointer[p] == 0xFF && p != 0; p-- {
 		a.ac_pointer[p] = 0
 	}
 	a.ac_pointer[p]++
@@ -83,26 +83,43 @@ func (a *ArithmeticCodec) deprecatedPropagateCarry2() {
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) RenormEncInterval() {
-	i := uint32(0)
+	if a.sink != nil {
+		a.renormSinkInterval()
+		return
+	}
 	for cont := true; cont; cont = a.length < AC__MinLength { // eval at least once
-		a.ac_pointer[i] = byte(a.base >> 24)
+		// Past the end of the buffer the encoder keeps coding but drops its
+		// output, so a runaway encoder cannot grow memory; StopEncoder reports
+		// the overflow.
+		if uint32(len(a.ac_pointer)) < a.buffer_size {
+			a.ac_pointer = append(a.ac_pointer, byte(a.base>>24))
+		} else {
+			a.overflow = true
+		}
 		a.base <<= 8
 		a.length <<= 8
-		i++
 	}
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) RenormDecInterval() {
-	i := uint32(0)
 	for cont := true; cont; cont = a.length < AC__MinLength {
-		i++
-		a.value = (a.value << 8) | uint32(a.ac_pointer[i])
+		a.value = (a.value << 8) | a.nextByte()
 		a.length <<= 8
 	}
 }
 
+// The decoder always reads a few bytes past the end of the code, so bytes
+// beyond the end of a user buffer are read as zeros instead of panicking.
+func (a *ArithmeticCodec) nextByte() uint32 {
+	if len(a.ac_pointer) < 2 {
+		return 0
+	}
+	a.ac_pointer = a.ac_pointer[1:]
+	return uint32(a.ac_pointer[0])
+}
+
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) PutBit(bit uint32) {
@@ -264,6 +281,10 @@ func (a *ArithmeticCodec) Encode_StaticDataModel(data uint32, M *StaticDataModel
 	var init_base uint32 = a.base
 
 	if data == M.last_symbol {
+		// An empty last interval would leave the rounding remainder.
+		if M.distribution[data] == DM__MaxCount {
+			panic(errZeroProbability("Encode_StaticDataModel", data))
+		}
 		x = M.distribution[data] * (a.length >> DM__LengthShift)
 		a.base += x
 		a.length -= x
@@ -279,6 +300,9 @@ func (a *ArithmeticCodec) Encode_StaticDataModel(data uint32, M *StaticDataModel
 	}
 
 	if a.length < AC__MinLength {
+		if a.length == 0 {
+			panic(errZeroProbability("Encode_StaticDataModel", data))
+		}
 		a.RenormEncInterval()
 	}
 }
@@ -293,6 +317,10 @@ func (a *ArithmeticCodec) Decode_StaticDataModel(M *StaticDataModel) uint32 {
 		a.length >>= DM__LengthShift
 		dv := a.value / a.length
 		t := dv >> M.table_shift
+		if t > M.table_size {
+			t = M.table_size // only a corrupt code takes value past length
+			a.corrupt = true
+		}
 
 		s = M.decoder_table[t]
 		n = M.decoder_table[t+1] + 1
@@ -350,7 +378,7 @@ func (a *ArithmeticCodec) Encode_AdaptiveDataModel(data uint32, M *AdaptiveDataM
 		a.length -= x
 	} else {
 		a.length >>= DM__LengthShift
-		x = M.distribution[data] * (a.length >> DM__LengthShift)
+		x = M.distribution[data] * a.length
 		a.base += x
 		a.length = M.distribution[data+1]*a.length - x
 	}
@@ -380,6 +408,10 @@ func (a *ArithmeticCodec) Decode_AdaptiveDataModel(M *AdaptiveDataModel) uint32
 		a.length >>= DM__LengthShift
 		dv := a.value / a.length
 		t := dv >> M.table_shift
+		if t > M.table_size {
+			t = M.table_size // only a corrupt code takes value past length
+			a.corrupt = true
+		}
 
 		s = M.decoder_table[t]
 		n = M.decoder_table[t+1] + 1
@@ -435,65 +467,105 @@ func (a *ArithmeticCodec) Decode_AdaptiveDataModel(M *AdaptiveDataModel) uint32
 // - - Other Arithmetic_Codec implementations  - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) StartEncoder() {
+	mustSucceed(a.TryStartEncoder())
+}
+
+func (a *ArithmeticCodec) TryStartEncoder() error {
 	if a.mode != Undefined {
-		AC_Error("cannot start encoder")
+		return codecError("StartEncoder", ErrWrongMode, "cannot start encoder")
 	}
 	if a.buffer_size == 0 {
-		AC_Error("no code buffer set")
+		return codecError("StartEncoder", ErrInvalidBufferSize, "no code buffer set")
 	}
 
 	a.mode = Encoder
 	a.base = 0
 	a.length = AC__MaxLength
-	a.ac_pointer = a.code_buffer
+	a.ac_pointer = a.code_buffer[:0]
+	a.overflow = false
+	return nil
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) StartDecoder() {
+	mustSucceed(a.TryStartDecoder())
+}
+
+func (a *ArithmeticCodec) TryStartDecoder() error {
 	if a.mode != Undefined {
-		AC_Error("cannot start decoder")
+		return codecError("StartDecoder", ErrWrongMode, "cannot start decoder")
 	}
 	if a.buffer_size == 0 {
-		AC_Error("no code buffer set")
+		return codecError("StartDecoder", ErrInvalidBufferSize, "no code buffer set")
 	}
 	a.mode = Decoder
+	a.corrupt = false
 	a.length = AC__MaxLength
-	a.ac_pointer = a.code_buffer[3:] // code_buffer + 3
-	a.value = uint32(a.code_buffer[0])<<24 | uint32(a.code_buffer[1])<<16 | uint32(a.code_buffer[2])<<8 | uint32(a.code_buffer[3])
+	a.ac_pointer = a.code_buffer // code_buffer + 3 once the first four bytes are read
+	a.value = uint32(a.code_buffer[0])<<24 | a.nextByte()<<16 | a.nextByte()<<8 | a.nextByte()
+	return nil
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) ReadFromFile(file *os.File) {
+	mustSucceed(a.TryReadFromFile(file))
+}
+
+func (a *ArithmeticCodec) TryReadFromFile(file *os.File) error {
+	if err := readCode(file, a.code_buffer, a.buffer_size); err != nil {
+		return err
+	}
+	return a.TryStartDecoder()
+}
+
+// readCode reads a code size header and that many code bytes into
+// code_buffer.
+func readCode(file *os.File, code_buffer []byte, buffer_size uint32) error {
 	var shift, code_bytes uint32 = 0, 0
 	var file_byte int32
 
 	for cont := true; cont; cont = file_byte&0x80 != 0 {
 		singleByte := make([]byte, 1)
-		n, err := file.Read(singleByte)
-		if err != nil {
-			AC_Error("error reading from file: " + err.Error())
+		if _, err := io.ReadFull(file, singleByte); err != nil {
+			return readError(err)
 		}
-		if n != 1 {
-			AC_Error("error reading from file: unexpected EOF | n = " + fmt.Sprint(n))
+		if shift > 28 {
+			return codecError("ReadFromFile", ErrCorruptInput, "code size header too long")
 		}
 		file_byte = int32(singleByte[0])
 		code_bytes |= uint32(file_byte&0x7F) << shift
 		shift += 7
 	}
-	if code_bytes > a.buffer_size {
-		AC_Error("code buffer overflow")
+	if code_bytes > buffer_size {
+		return codecError("ReadFromFile", ErrBufferOverflow, fmt.Sprint(code_bytes))
 	}
+	if _, err := io.ReadFull(file, code_buffer[:code_bytes]); err != nil {
+		return readError(err)
+	}
+	return nil
+}
 
-	a.StartDecoder()
+// A file that ends early is corrupt; anything else is an I/O failure.
+func readError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return codecError("ReadFromFile", ErrCorruptInput, "unexpected end of file")
+	}
+	return codecError("ReadFromFile", err, "")
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) StopEncoder() uint32 {
+	code_bytes, err := a.TryStopEncoder()
+	mustSucceed(err)
+	return code_bytes
+}
+
+func (a *ArithmeticCodec) TryStopEncoder() (uint32, error) {
 	if a.mode != Encoder {
-		AC_Error("invalid to stop encoder")
+		return 0, codecError("StopEncoder", ErrWrongMode, "invalid to stop encoder")
 	}
 	a.mode = Undefined
 
@@ -513,20 +585,39 @@ func (a *ArithmeticCodec) StopEncoder() uint32 {
 
 	a.RenormEncInterval()
 
-	code_bytes := uint32(len(a.code_buffer) - len(a.ac_pointer))
+	if a.sink != nil {
+		return a.stopSink()
+	}
 
-	if code_bytes > a.buffer_size {
-		AC_Error("code buffer overflow")
+	if a.overflow {
+		return 0, codecError("StopEncoder", ErrBufferOverflow, fmt.Sprintf("code exceeds %d bytes", a.buffer_size))
 	}
+	code_bytes := uint32(len(a.ac_pointer))
 
-	return code_bytes
+	return code_bytes, nil
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) WriteToFile(file *os.File) uint32 {
-	var header_bytes, code_bytes uint32 = 0, a.StopEncoder()
-	var nb = code_bytes
+	n, err := a.TryWriteToFile(file)
+	mustSucceed(err)
+	return n
+}
+
+func (a *ArithmeticCodec) TryWriteToFile(file *os.File) (uint32, error) {
+	code_bytes, err := a.TryStopEncoder()
+	if err != nil {
+		return 0, err
+	}
+	return writeCode(file, a.code_buffer[:code_bytes])
+}
+
+// writeCode writes a code size header followed by the code, and returns the
+// number of bytes written.
+func writeCode(file *os.File, code []byte) (uint32, error) {
+	var header_bytes uint32
+	var nb = uint32(len(code))
 
 	for cont := true; cont; cont = nb > 0 {
 		file_byte := int(nb & 0x7F)
@@ -534,31 +625,28 @@ func (a *ArithmeticCodec) WriteToFile(file *os.File) uint32 {
 		if nb > 0 {
 			file_byte |= 0x80
 		}
-		n, err := file.Write([]byte{byte(file_byte)})
-		if err != nil {
-			AC_Error("cannot write compressed data to file: " + err.Error())
-		}
-		if n != 1 {
-			AC_Error("cannot write compressed data to file: unexpected EOF | n = " + fmt.Sprint(n))
+		if _, err := file.Write([]byte{byte(file_byte)}); err != nil {
+			return 0, codecError("WriteToFile", err, "cannot write compressed data to file")
 		}
 		header_bytes++
 	}
-	n, err := file.Write(a.code_buffer[:code_bytes])
-	if err != nil {
-		AC_Error("cannot write compressed data to file: " + err.Error())
-	}
-	if n != int(code_bytes) {
-		AC_Error("cannot write compressed data to file: unexpected EOF | n = " + fmt.Sprint(n))
+	if _, err := file.Write(code); err != nil {
+		return 0, codecError("WriteToFile", err, "cannot write compressed data to file")
 	}
 
-	return header_bytes + code_bytes
+	return header_bytes + uint32(len(code)), nil
 }
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
 func (a *ArithmeticCodec) StopDecoder() {
+	mustSucceed(a.TryStopDecoder())
+}
+
+func (a *ArithmeticCodec) TryStopDecoder() error {
 	if a.mode != Decoder {
-		AC_Error("invalid to stop decoder")
+		return codecError("StopDecoder", ErrWrongMode, "invalid to stop decoder")
 	}
 	a.mode = Undefined
+	return nil
 }
@@ -0,0 +1,626 @@
+package FastAC
+
+import (
+	"fmt"
+	"os"
+)
+
+// The Int3264 codec keeps base and length in 64 bits and renormalizes 32 bits
+// at a time. Models are shared with the 32-bit codec, but because length never
+// drops below 2^32 the interval split x = p * (length >> shift) loses at most
+// 2^-17 of the interval instead of 2^-9, and PutBits/GetBits accept up to 32
+// bits.
+//
+// The extra length also allows finer probabilities: StaticBitModel64 and
+// StaticDataModel64 keep theirs over 2^BM64__LengthShift and
+// 2^DM64__LengthShift, which loses no more of the interval than the 32-bit
+// codec with its own models, and codes very skewed sources much closer to
+// their entropy.
+const (
+	AC64__MinLength = 1 << 32
+	AC64__MaxLength = 0xFFFFFFFFFFFFFFFF
+
+	BM64__LengthShift = 24
+	BM64__MaxCount    = 1 << BM64__LengthShift
+
+	DM64__LengthShift = 24
+	DM64__MaxCount    = 1 << DM64__LengthShift
+)
+
+type ArithmeticCodec64 struct {
+	codeBuffer
+	ac_pointer          []byte // as in ArithmeticCodec
+	base, value, length uint64
+	overflow            bool
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Static functions  - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func NewArithmeticCodec64(max_code_bytes uint32, user_buffer []byte) (*ArithmeticCodec64, error) {
+	codec := new(ArithmeticCodec64)
+	if err := codec.TrySetBuffer(max_code_bytes, user_buffer); err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Coding implementations  - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) PropagateCarry() {
+	if a.overflow {
+		return
+	}
+	p := len(a.ac_pointer) - 1
+	for ; a.ac_pointer[p] == 0xFF && p != 0; p-- {
+		a.ac_pointer[p] = 0
+	}
+	a.ac_pointer[p]++
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) RenormEncInterval() {
+	for cont := true; cont; cont = a.length < AC64__MinLength {
+		// As in ArithmeticCodec, output past the buffer is dropped.
+		if uint32(len(a.ac_pointer))+4 <= a.buffer_size {
+			a.ac_pointer = append(a.ac_pointer, byte(a.base>>56), byte(a.base>>48), byte(a.base>>40), byte(a.base>>32))
+		} else {
+			a.overflow = true
+		}
+		a.base <<= 32
+		a.length <<= 32
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) RenormDecInterval() {
+	for cont := true; cont; cont = a.length < AC64__MinLength {
+		a.value = (a.value << 32) | a.nextWord()
+		a.length <<= 32
+	}
+}
+
+func (a *ArithmeticCodec64) nextWord() uint64 {
+	return a.nextByte()<<24 | a.nextByte()<<16 | a.nextByte()<<8 | a.nextByte()
+}
+
+// Bytes past the end of the buffer are read as zeros, as in ArithmeticCodec.
+func (a *ArithmeticCodec64) nextByte() uint64 {
+	if len(a.ac_pointer) < 2 {
+		return 0
+	}
+	a.ac_pointer = a.ac_pointer[1:]
+	return uint64(a.ac_pointer[0])
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) PutBit(bit uint32) {
+	a.length >>= 1
+	if bit > 0 {
+		init_base := a.base
+		a.base += a.length
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+	}
+	if a.length < AC64__MinLength {
+		a.RenormEncInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) GetBit() bool {
+	a.length >>= 1
+	bit := (a.value >= a.length)
+	if bit {
+		a.value -= a.length
+	}
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) PutBits(data, bits uint32) {
+	init_base := a.base
+	a.length >>= bits
+	a.base += uint64(data) * a.length
+
+	if init_base > a.base {
+		a.PropagateCarry()
+	}
+	if a.length < AC64__MinLength {
+		a.RenormEncInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) GetBits(bits uint32) uint32 {
+	a.length >>= bits
+	s := a.value / a.length
+	a.value -= a.length * s
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+	return uint32(s)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Encode_StaticBitModel(bit uint32, M *StaticBitModel) {
+	x := uint64(M.bit_0_prob) * (a.length >> BM__LengthShift)
+	if bit == 0 {
+		a.length = x
+	} else {
+		init_base := a.base
+		a.base += x
+		a.length -= x
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormEncInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Decode_StaticBitModel(M *StaticBitModel) uint32 {
+	x := uint64(M.bit_0_prob) * (a.length >> BM__LengthShift)
+	bit := uint32(0)
+	if a.value >= x {
+		bit = 1
+	}
+
+	if bit == 0 {
+		a.length = x
+	} else {
+		a.value -= x
+		a.length -= x
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Encode_AdaptiveBitModel(bit uint32, M *AdaptiveBitModel) {
+	x := uint64(M.bit_0_prob) * (a.length >> BM__LengthShift)
+
+	if bit == 0 {
+		a.length = x
+		M.bit_0_count++
+	} else {
+		init_base := a.base
+		a.base += x
+		a.length -= x
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormEncInterval()
+	}
+
+	M.bits_until_update--
+	if M.bits_until_update == 0 {
+		M.Update()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Decode_AdaptiveBitModel(M *AdaptiveBitModel) uint32 {
+	x := uint64(M.bit_0_prob) * (a.length >> BM__LengthShift)
+	bit := uint32(0)
+	if a.value >= x {
+		bit = 1
+	}
+
+	if bit == 0 {
+		a.length = x
+		M.bit_0_count++
+	} else {
+		a.value -= x
+		a.length -= x
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+
+	M.bits_until_update--
+	if M.bits_until_update == 0 {
+		M.Update()
+	}
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Encode_StaticDataModel(data uint32, M *StaticDataModel) {
+	var x uint64
+	var init_base uint64 = a.base
+
+	if data == M.last_symbol {
+		// An empty last interval would leave the rounding remainder.
+		if M.distribution[data] == DM__MaxCount {
+			panic(errZeroProbability("Encode_StaticDataModel", data))
+		}
+		x = uint64(M.distribution[data]) * (a.length >> DM__LengthShift)
+		a.base += x
+		a.length -= x
+	} else {
+		a.length >>= DM__LengthShift
+		x = uint64(M.distribution[data]) * a.length
+		a.base += x
+		a.length = uint64(M.distribution[data+1])*a.length - x
+	}
+
+	if init_base > a.base {
+		a.PropagateCarry()
+	}
+
+	if a.length < AC64__MinLength {
+		if a.length == 0 {
+			panic(errZeroProbability("Encode_StaticDataModel", data))
+		}
+		a.RenormEncInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Decode_StaticDataModel(M *StaticDataModel) uint32 {
+	s, x, y := a.decodeInterval(M.distribution, M.decoder_table, M.data_symbols, M.last_symbol, M.table_shift, DM__LengthShift)
+
+	a.value -= x
+	a.length = y - x
+
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+
+	return s
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Encode_AdaptiveDataModel(data uint32, M *AdaptiveDataModel) {
+	var x uint64
+	var init_base uint64 = a.base
+
+	if data == M.last_symbol {
+		x = uint64(M.distribution[data]) * (a.length >> DM__LengthShift)
+		a.base += x
+		a.length -= x
+	} else {
+		a.length >>= DM__LengthShift
+		x = uint64(M.distribution[data]) * a.length
+		a.base += x
+		a.length = uint64(M.distribution[data+1])*a.length - x
+	}
+
+	if init_base > a.base {
+		a.PropagateCarry()
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormEncInterval()
+	}
+
+	M.symbol_count[data]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update(true)
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Decode_AdaptiveDataModel(M *AdaptiveDataModel) uint32 {
+	s, x, y := a.decodeInterval(M.distribution, M.decoder_table, M.data_symbols, M.last_symbol, M.table_shift, DM__LengthShift)
+
+	a.value -= x
+	a.length = y - x
+
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+
+	M.symbol_count[s]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update(false)
+	}
+	return s
+}
+
+func (a *ArithmeticCodec64) Encode_StaticBitModel64(bit uint32, M *StaticBitModel64) {
+	x := uint64(M.bit_0_prob) * (a.length >> BM64__LengthShift)
+	if bit == 0 {
+		a.length = x
+	} else {
+		init_base := a.base
+		a.base += x
+		a.length -= x
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormEncInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Decode_StaticBitModel64(M *StaticBitModel64) uint32 {
+	x := uint64(M.bit_0_prob) * (a.length >> BM64__LengthShift)
+	bit := uint32(0)
+	if a.value >= x {
+		bit = 1
+	}
+
+	if bit == 0 {
+		a.length = x
+	} else {
+		a.value -= x
+		a.length -= x
+	}
+
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Encode_StaticDataModel64(data uint32, M *StaticDataModel64) {
+	var x uint64
+	var init_base uint64 = a.base
+	sdm := &M.model
+
+	if data == sdm.last_symbol {
+		if sdm.distribution[data] == DM64__MaxCount {
+			panic(errZeroProbability("Encode_StaticDataModel64", data))
+		}
+		x = uint64(sdm.distribution[data]) * (a.length >> DM64__LengthShift)
+		a.base += x
+		a.length -= x
+	} else {
+		a.length >>= DM64__LengthShift
+		x = uint64(sdm.distribution[data]) * a.length
+		a.base += x
+		a.length = uint64(sdm.distribution[data+1])*a.length - x
+	}
+
+	if init_base > a.base {
+		a.PropagateCarry()
+	}
+
+	if a.length < AC64__MinLength {
+		if a.length == 0 {
+			panic(errZeroProbability("Encode_StaticDataModel64", data))
+		}
+		a.RenormEncInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) Decode_StaticDataModel64(M *StaticDataModel64) uint32 {
+	sdm := &M.model
+	s, x, y := a.decodeInterval(sdm.distribution, sdm.decoder_table, sdm.data_symbols, sdm.last_symbol, sdm.table_shift, DM64__LengthShift)
+
+	a.value -= x
+	a.length = y - x
+
+	if a.length < AC64__MinLength {
+		a.RenormDecInterval()
+	}
+
+	return s
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// decodeInterval finds the symbol whose interval holds value, returning the
+// symbol and the interval bounds. The data models share the same layout, with
+// widths over 1 << length_shift.
+func (a *ArithmeticCodec64) decodeInterval(distribution, decoder_table []uint32, data_symbols, last_symbol, table_shift, length_shift uint32) (uint32, uint64, uint64) {
+	var n, s uint32
+	var x uint64
+	var y uint64 = a.length
+
+	if decoder_table != nil {
+		a.length >>= length_shift
+		dv := uint32(a.value / a.length)
+		t := dv >> table_shift
+		if last := uint32(len(decoder_table)) - 2; t > last {
+			t = last // only a corrupt code takes value past length
+		}
+
+		s = decoder_table[t]
+		n = decoder_table[t+1] + 1
+
+		for n > s+1 {
+			m := (s + n) >> 1
+			if distribution[m] > dv {
+				n = m
+			} else {
+				s = m
+			}
+		}
+
+		x = uint64(distribution[s]) * a.length
+		if s != last_symbol {
+			y = uint64(distribution[s+1]) * a.length
+		}
+	} else {
+		a.length >>= length_shift
+		n = data_symbols
+		m := n >> 1
+
+		for cont := true; cont; cont = m != s {
+			z := a.length * uint64(distribution[m])
+			if z > a.value {
+				n = m
+				y = z
+			} else {
+				s = m
+				x = z
+			}
+			m = (s + n) >> 1
+		}
+	}
+	return s, x, y
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Other Arithmetic_Codec implementations  - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) StartEncoder() {
+	mustSucceed(a.TryStartEncoder())
+}
+
+func (a *ArithmeticCodec64) TryStartEncoder() error {
+	if a.mode != Undefined {
+		return codecError("StartEncoder", ErrWrongMode, "cannot start encoder")
+	}
+	if a.buffer_size == 0 {
+		return codecError("StartEncoder", ErrInvalidBufferSize, "no code buffer set")
+	}
+
+	a.mode = Encoder
+	a.base = 0
+	a.length = AC64__MaxLength
+	a.ac_pointer = a.code_buffer[:0]
+	a.overflow = false
+	return nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) StartDecoder() {
+	mustSucceed(a.TryStartDecoder())
+}
+
+func (a *ArithmeticCodec64) TryStartDecoder() error {
+	if a.mode != Undefined {
+		return codecError("StartDecoder", ErrWrongMode, "cannot start decoder")
+	}
+	if a.buffer_size == 0 {
+		return codecError("StartDecoder", ErrInvalidBufferSize, "no code buffer set")
+	}
+	a.mode = Decoder
+	a.length = AC64__MaxLength
+	a.ac_pointer = a.code_buffer // code_buffer + 7 once the first eight bytes are read
+	a.value = uint64(a.code_buffer[0])<<56 | a.nextByte()<<48 | a.nextByte()<<40 | a.nextByte()<<32 | a.nextWord()
+	return nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) ReadFromFile(file *os.File) {
+	mustSucceed(a.TryReadFromFile(file))
+}
+
+func (a *ArithmeticCodec64) TryReadFromFile(file *os.File) error {
+	if err := readCode(file, a.code_buffer, a.buffer_size); err != nil {
+		return err
+	}
+	return a.TryStartDecoder()
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) StopEncoder() uint32 {
+	code_bytes, err := a.TryStopEncoder()
+	mustSucceed(err)
+	return code_bytes
+}
+
+func (a *ArithmeticCodec64) TryStopEncoder() (uint32, error) {
+	if a.mode != Encoder {
+		return 0, codecError("StopEncoder", ErrWrongMode, "invalid to stop encoder")
+	}
+	a.mode = Undefined
+
+	init_base := a.base
+
+	// With room for it, a point 2^32 into the interval only needs its top
+	// 32 bits written; otherwise the whole of base is.
+	if a.length > 2*AC64__MinLength {
+		a.base += AC64__MinLength
+		a.length = AC64__MinLength >> 1
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+		a.RenormEncInterval()
+	} else {
+		a.base += AC64__MinLength >> 1
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+		if uint32(len(a.ac_pointer))+8 <= a.buffer_size {
+			a.ac_pointer = append(a.ac_pointer, byte(a.base>>56), byte(a.base>>48), byte(a.base>>40), byte(a.base>>32),
+				byte(a.base>>24), byte(a.base>>16), byte(a.base>>8), byte(a.base))
+		} else {
+			a.overflow = true
+		}
+	}
+
+	if a.overflow {
+		return 0, codecError("StopEncoder", ErrBufferOverflow, fmt.Sprintf("code exceeds %d bytes", a.buffer_size))
+	}
+	code_bytes := uint32(len(a.ac_pointer))
+
+	return code_bytes, nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) WriteToFile(file *os.File) uint32 {
+	n, err := a.TryWriteToFile(file)
+	mustSucceed(err)
+	return n
+}
+
+func (a *ArithmeticCodec64) TryWriteToFile(file *os.File) (uint32, error) {
+	code_bytes, err := a.TryStopEncoder()
+	if err != nil {
+		return 0, err
+	}
+	return writeCode(file, a.code_buffer[:code_bytes])
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec64) StopDecoder() {
+	mustSucceed(a.TryStopDecoder())
+}
+
+func (a *ArithmeticCodec64) TryStopDecoder() error {
+	if a.mode != Decoder {
+		return codecError("StopDecoder", ErrWrongMode, "invalid to stop decoder")
+	}
+	a.mode = Undefined
+	return nil
+}
@@ -0,0 +1,227 @@
+package FastAC
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestArithmeticCodec64_RoundTrip(t *testing.T) {
+	const n = 100000
+	rg := initRandomGenerator(64)
+	static_bit := initStaticBitModel()
+	static_bit.SetProbability0(0.001)
+	static_data := initStaticDataModel()
+	static_data.SetDistribution(100, nil)
+
+	data := make([]uint32, n)
+	for k := range data {
+		data[k] = rg.Word()
+	}
+	code := func(codec *ArithmeticCodec64, k int, bit_model *AdaptiveBitModel, data_model *AdaptiveDataModel) uint32 {
+		w := data[k]
+		switch k % 6 {
+		case 0:
+			if codec.mode == Encoder {
+				codec.PutBits(w, 32)
+				return w
+			}
+			return codec.GetBits(32)
+		case 1:
+			if codec.mode == Encoder {
+				codec.Encode_StaticBitModel(w&1, static_bit)
+				return w & 1
+			}
+			return codec.Decode_StaticBitModel(static_bit)
+		case 2:
+			if codec.mode == Encoder {
+				codec.Encode_AdaptiveBitModel(w>>31, bit_model)
+				return w >> 31
+			}
+			return codec.Decode_AdaptiveBitModel(bit_model)
+		case 3:
+			if codec.mode == Encoder {
+				codec.Encode_StaticDataModel(w%100, static_data)
+				return w % 100
+			}
+			return codec.Decode_StaticDataModel(static_data)
+		case 4:
+			if codec.mode == Encoder {
+				codec.PutBit(w & 2)
+				return w & 2 >> 1
+			}
+			if codec.GetBit() {
+				return 1
+			}
+			return 0
+		default:
+			if codec.mode == Encoder {
+				codec.Encode_AdaptiveDataModel(w%2048, data_model)
+				return w % 2048
+			}
+			return codec.Decode_AdaptiveDataModel(data_model)
+		}
+	}
+
+	file, err := os.CreateTemp(t.TempDir(), "ac64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	encoder, err := NewArithmeticCodec64(1<<20, nil)
+	if err != nil {
+		t.Fatalf("NewArithmeticCodec64() error = %v", err)
+	}
+	encoder.StartEncoder()
+	bit_model, data_model := initAdaptiveBitModel(), initAdaptiveDataModel(2048)
+	want := make([]uint32, n)
+	for k := range data {
+		want[k] = code(encoder, k, bit_model, data_model)
+	}
+	encoder.WriteToFile(file)
+	file.Seek(0, 0)
+
+	decoder := initCodec(Int3264, 1<<20, nil).(*ArithmeticCodec64)
+	decoder.ReadFromFile(file)
+	bit_model, data_model = initAdaptiveBitModel(), initAdaptiveDataModel(2048)
+	for k := range data {
+		if s := code(decoder, k, bit_model, data_model); s != want[k] {
+			t.Fatalf("symbol %d: decoded %d, want %d", k, s, want[k])
+		}
+	}
+	decoder.StopDecoder()
+}
+
+func TestNewCodec(t *testing.T) {
+	if _, ok := initCodec(Int3232, 16, make([]byte, 16)).(*ArithmeticCodec); !ok {
+		t.Errorf("NewCodec(Int3232) is not an *ArithmeticCodec")
+	}
+	if _, err := NewCodec(Version(9), 0, nil); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("NewCodec(Version(9)) error = %v, want ErrInvalidVersion", err)
+	}
+	if codec, err := NewCodec(Int3264, 8, make([]byte, 8)); codec != nil || !errors.Is(err, ErrInvalidBufferSize) {
+		t.Errorf("NewCodec(Int3264, 8) = %v, %v, want nil, ErrInvalidBufferSize", codec, err)
+	}
+
+	// An overflowing encoder drops its output instead of growing.
+	codec := initCodec(Int3264, 16, make([]byte, 16)).(*ArithmeticCodec64)
+	codec.StartEncoder()
+	for k := 0; k < 64; k++ {
+		codec.PutBits(0x5A5A5A5A, 32)
+	}
+	if len(codec.ac_pointer) > 16 {
+		t.Errorf("encoder holds %d bytes in a buffer of 16", len(codec.ac_pointer))
+	}
+	if _, err := codec.TryStopEncoder(); !errors.Is(err, ErrBufferOverflow) {
+		t.Errorf("TryStopEncoder() error = %v, want ErrBufferOverflow", err)
+	}
+}
+
+func TestArithmeticCodec64_Precision(t *testing.T) {
+	// Three symbols each with probability 10^-6: the 15-bit widths of
+	// StaticDataModel must give them 2^-15 each, taken from the common symbol.
+	const n = 3000000
+	rg := initRandomGenerator(64)
+	data := make([]uint32, n)
+	frequency := make([]uint32, 4)
+	for k := range data {
+		if rg.Integer(1000000) < 3 {
+			data[k] = 1 + rg.Integer(3)
+		}
+		frequency[data[k]]++
+	}
+	entropy := 0.0
+	for _, f := range frequency {
+		if f > 0 {
+			entropy -= float64(f) * math.Log2(float64(f)/n)
+		}
+	}
+
+	model := initStaticDataModel()
+	model.SetFrequencies(frequency)
+	model64 := NewStaticDataModel64()
+	model64.SetFrequencies(frequency)
+
+	codec, err := NewArithmeticCodec64(1<<16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec.StartEncoder()
+	for _, s := range data {
+		codec.Encode_StaticDataModel(s, model)
+	}
+	size := codec.StopEncoder()
+	codec.StartEncoder()
+	for _, s := range data {
+		codec.Encode_StaticDataModel64(s, model64)
+	}
+	size64 := codec.StopEncoder()
+
+	codec.StartDecoder()
+	for k, s := range data {
+		if got := codec.Decode_StaticDataModel64(model64); got != s {
+			t.Fatalf("symbol %d: decoded %d, want %d", k, got, s)
+		}
+	}
+	codec.StopDecoder()
+
+	t.Logf("%d symbols, entropy %.0f bytes: %d bytes with StaticDataModel, %d with StaticDataModel64", n, entropy/8, size, size64)
+	if float64(size64) > entropy/8+16 || 2*size64 > size {
+		t.Errorf("coded %d symbols of entropy %.0f bytes in %d bytes, %d with 15-bit widths", n, entropy/8, size64, size)
+	}
+
+	// A larger alphabet decodes through the decoder table.
+	frequency = make([]uint32, 300)
+	for k := range frequency {
+		frequency[k] = uint32(k * k)
+	}
+	model64.SetFrequenciesWithZeros(frequency)
+	codec.StartEncoder()
+	for k := 1; k < 20000; k++ {
+		codec.Encode_StaticDataModel64(uint32(k%300)|1, model64)
+	}
+	codec.StopEncoder()
+	codec.StartDecoder()
+	for k := 1; k < 20000; k++ {
+		if got := codec.Decode_StaticDataModel64(model64); got != uint32(k%300)|1 {
+			t.Fatalf("300 symbols, symbol %d: decoded %d, want %d", k, got, k%300|1)
+		}
+	}
+	codec.StopDecoder()
+
+	// A corrupt code decodes to some symbols, without reading outside the
+	// decoder table.
+	for k := range codec.code_buffer {
+		codec.code_buffer[k] = 0xFF
+	}
+	codec.StartDecoder()
+	for k := 0; k < 100; k++ {
+		codec.Decode_StaticDataModel64(model64)
+	}
+	codec.StopDecoder()
+
+	// A bit with probability 10^-6 of a 1, which StaticBitModel cannot
+	// represent.
+	bit_model := NewStaticBitModel64()
+	bit_model.SetProbability0(1 - 1e-6)
+	if p := bit_model.Probability(1); math.Abs(p-1e-6) > 1e-7 {
+		t.Errorf("Probability(1) = %g, want 1e-6", p)
+	}
+	codec.StartEncoder()
+	for _, s := range data {
+		codec.Encode_StaticBitModel64(s&1, bit_model)
+	}
+	codec.StopEncoder()
+	codec.StartDecoder()
+	for k, s := range data {
+		if got := codec.Decode_StaticBitModel64(bit_model); got != s&1 {
+			t.Fatalf("bit %d: decoded %d, want %d", k, got, s&1)
+		}
+	}
+	codec.StopDecoder()
+	if err := bit_model.TrySetProbability0(1); !errors.Is(err, ErrInvalidProbability) {
+		t.Errorf("TrySetProbability0(1) error = %v, want ErrInvalidProbability", err)
+	}
+}
@@ -1,6 +1,7 @@
 package FastAC
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -10,20 +11,79 @@ func TestArithmeticCodec_SetBuffer(t *testing.T) {
 		user_buffer    []byte
 	}
 	tests := []struct {
-		name string
-		args args
+		name      string
+		args      args
+		wantPanic bool
 	}{
 		{name: "something", args: args{max_code_bytes: 16, user_buffer: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}}},
 		{name: "nil", args: args{max_code_bytes: 0, user_buffer: nil}},
-		{name: "empty", args: args{max_code_bytes: 0, user_buffer: []byte{}}}, // want panic here...
+		{name: "empty", args: args{max_code_bytes: 0, user_buffer: []byte{}}, wantPanic: true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantPanic {
+					t.Errorf("initArithmeticCodec() panic = %v, wantPanic %v", r, tt.wantPanic)
+				}
+			}()
 			initArithmeticCodec(tt.args.max_code_bytes, tt.args.user_buffer)
 		})
 	}
 }
 
+func TestArithmeticCodec_Errors(t *testing.T) {
+	var ce *CodecError
+
+	_, err := NewArithmeticCodec(8, make([]byte, 8))
+	if !errors.Is(err, ErrInvalidBufferSize) || !errors.As(err, &ce) || ce.Op != "SetBuffer" {
+		t.Errorf("NewArithmeticCodec() error = %v, want ErrInvalidBufferSize from SetBuffer", err)
+	}
+
+	codec, err := NewArithmeticCodec(0, nil)
+	if err != nil {
+		t.Fatalf("NewArithmeticCodec() error = %v", err)
+	}
+	if err := codec.TryStartEncoder(); !errors.Is(err, ErrInvalidBufferSize) {
+		t.Errorf("TryStartEncoder() without buffer error = %v, want ErrInvalidBufferSize", err)
+	}
+	if _, err := codec.TryStopEncoder(); !errors.Is(err, ErrWrongMode) {
+		t.Errorf("TryStopEncoder() while idle error = %v, want ErrWrongMode", err)
+	}
+
+	codec.SetBuffer(16, make([]byte, 16))
+	codec.StartEncoder()
+	if err := codec.TrySetBuffer(64, nil); !errors.Is(err, ErrWrongMode) {
+		t.Errorf("TrySetBuffer() while encoding error = %v, want ErrWrongMode", err)
+	}
+	for k := 0; k < 64; k++ {
+		codec.PutBits(0x5A5A, 16)
+	}
+	// An overflowing encoder drops its output instead of growing.
+	if len(codec.ac_pointer) > 16 {
+		t.Errorf("encoder holds %d bytes in a buffer of 16", len(codec.ac_pointer))
+	}
+	if _, err := codec.TryStopEncoder(); !errors.Is(err, ErrBufferOverflow) {
+		t.Errorf("TryStopEncoder() error = %v, want ErrBufferOverflow", err)
+	}
+
+	if _, err := NewAdaptiveDataModel(1 << 12); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("NewAdaptiveDataModel() error = %v, want ErrInvalidAlphabet", err)
+	}
+	if err := NewStaticBitModel().TrySetProbability0(1.5); !errors.Is(err, ErrInvalidProbability) {
+		t.Errorf("TrySetProbability0() error = %v, want ErrInvalidProbability", err)
+	}
+	if err := NewStaticDataModel().TrySetDistribution(3, []float64{0.5, 0.25, 0.5}); !errors.Is(err, ErrInvalidProbability) {
+		t.Errorf("TrySetDistribution() error = %v, want ErrInvalidProbability", err)
+	}
+
+	defer func() {
+		if r, ok := recover().(error); !ok || !errors.Is(r, ErrInvalidAlphabet) {
+			t.Errorf("SetAlphabet() panic = %v, want ErrInvalidAlphabet", r)
+		}
+	}()
+	new(AdaptiveDataModel).SetAlphabet(1)
+}
+
 func TestArithmeticCodec_PropagateCarry(t *testing.T) {
 	type args struct {
 		max_code_bytes uint32
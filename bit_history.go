@@ -0,0 +1,206 @@
+package FastAC
+
+import "fmt"
+
+// A bit history is a state of 8 bits that stands for the counts n0 and n1 of
+// zeros and ones seen in a context, as in the nonstationary counters of PAQ.
+// When a bit is seen its count grows and the other count, if above 2, is
+// roughly halved, so a history forgets old bits as soon as the source
+// changes. Each count is bounded by the other, which keeps the reachable
+// pairs within 256 states. State 0 is the empty history.
+//
+// A history does not give a probability by itself: a stateMap learns, for
+// each state, how often a 1 actually followed it.
+
+// bitHistoryBound is the largest count allowed beside each value of the
+// other count; the last entry holds for all larger ones.
+var bitHistoryBound = [...]uint8{48, 40, 24, 14, 10, 7, 6, 5}
+
+type bitHistoryTable struct {
+	next   [256][2]uint8 // state after a 0 and after a 1
+	counts [256][2]uint8 // n0 and n1 of each state
+	states int
+}
+
+var bitHistory = buildBitHistoryTable()
+
+func historyBound(other uint8) uint8 {
+	if int(other) >= len(bitHistoryBound) {
+		return bitHistoryBound[len(bitHistoryBound)-1]
+	}
+	return bitHistoryBound[other]
+}
+
+// historyNext returns the counts after bit is seen with counts n.
+func historyNext(n [2]uint8, bit uint32) [2]uint8 {
+	n[bit]++
+	if n[1-bit] > 2 {
+		n[1-bit] = n[1-bit]/2 + 1
+	}
+	if b := historyBound(n[1-bit]); n[bit] > b {
+		n[bit] = b
+	}
+	if b := historyBound(n[bit]); n[1-bit] > b {
+		n[1-bit] = b
+	}
+	return n
+}
+
+// buildBitHistoryTable numbers the states in the order they are reached from
+// the empty history.
+func buildBitHistoryTable() *bitHistoryTable {
+	t := new(bitHistoryTable)
+	index := map[[2]uint8]int{{0, 0}: 0}
+	for s := 0; s < len(index); s++ {
+		for bit := uint32(0); bit < 2; bit++ {
+			n := historyNext(t.counts[s], bit)
+			k, ok := index[n]
+			if !ok {
+				k = len(index)
+				if k > 255 {
+					panic("bit history needs more than 256 states")
+				}
+				index[n] = k
+				t.counts[k] = n
+			}
+			t.next[s][bit] = uint8(k)
+		}
+	}
+	t.states = len(index)
+	return t
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Probabilities of bit histories  - - - - - - - - - - - - - - - - - - - -
+
+const stateMapLimit = 127 // largest count of a stateMap entry
+
+// stateMap maps each of a number of contexts and bit histories to the
+// probability of a 1. Each entry holds a 22-bit probability and, in its low
+// 10 bits, the number of times it was updated; the update moves the
+// probability by 1/(count + 1.5) of the error, so new entries learn fast and
+// old ones average over about stateMapLimit bits.
+type stateMap struct {
+	entries []uint32
+}
+
+// newStateMap returns a map of contexts * 256 entries, the entry of context c
+// and history s at c * 256 + s, each starting from the counts of s.
+func newStateMap(contexts uint32) stateMap {
+	sm := stateMap{make([]uint32, contexts<<8)}
+	sm.reset()
+	return sm
+}
+
+func (sm *stateMap) reset() {
+	for k := range sm.entries {
+		n := bitHistory.counts[k&255]
+		p := (2*uint32(n[1]) + 1) << 22 / (2*uint32(n[0]+n[1]) + 2)
+		sm.entries[k] = p << 10
+	}
+}
+
+// p returns the probability of a 1 of entry k, over SM__MaxProb and between 1
+// and SM__MaxProb - 1.
+func (sm *stateMap) p(k uint32) uint32 {
+	if p := sm.entries[k] >> 16; p != 0 {
+		return p
+	}
+	return 1
+}
+
+func (sm *stateMap) update(k, bit uint32) {
+	e := sm.entries[k]
+	n, p := e&1023, int64(e>>10)
+	p += (int64(bit)<<22 - p) * 2 / int64(2*n+3)
+	if n < stateMapLimit {
+		n++
+	}
+	sm.entries[k] = uint32(p)<<10 | n
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// HistoryBitModel codes bits in a number of contexts, keeping a bit history
+// for each and learning the probability of a 1 after each history. Because
+// histories forget quickly, it follows sources that switch between
+// distributions much better than AdaptiveBitModel, and because the
+// probabilities are learned across all contexts, a context needs few bits to
+// be predicted well.
+//
+// Select the context with SetContext before coding each bit.
+type HistoryBitModel struct {
+	states  []uint8 // bit history of each context
+	probs   stateMap
+	context uint32
+}
+
+// NewHistoryBitModel returns a model for contexts contexts, from 1 to 2^24,
+// with context 0 selected.
+func NewHistoryBitModel(contexts uint32) (*HistoryBitModel, error) {
+	if contexts < 1 || contexts > 1<<24 {
+		return nil, codecError("NewHistoryBitModel", ErrInvalidContext, fmt.Sprintf("%d contexts", contexts))
+	}
+	return &HistoryBitModel{states: make([]uint8, contexts), probs: newStateMap(1)}, nil
+}
+
+func initHistoryBitModel(contexts uint32) *HistoryBitModel {
+	h, err := NewHistoryBitModel(contexts)
+	mustSucceed(err)
+	return h
+}
+
+// Contexts returns the number of contexts of the model.
+func (h *HistoryBitModel) Contexts() uint32 {
+	return uint32(len(h.states))
+}
+
+func (h *HistoryBitModel) SetContext(context uint32) {
+	mustSucceed(h.TrySetContext(context))
+}
+
+func (h *HistoryBitModel) TrySetContext(context uint32) error {
+	if context >= uint32(len(h.states)) {
+		return codecError("SetContext", ErrInvalidContext, fmt.Sprintf("context %d of %d", context, len(h.states)))
+	}
+	h.context = context
+	return nil
+}
+
+// Reset empties every history and forgets the learned probabilities.
+func (h *HistoryBitModel) Reset() {
+	for k := range h.states {
+		h.states[k] = 0
+	}
+	h.probs.reset()
+	h.context = 0
+}
+
+// p1 returns the probability of a 1 in the selected context, over
+// SM__MaxProb.
+func (h *HistoryBitModel) p1() uint32 {
+	return h.probs.p(uint32(h.states[h.context]))
+}
+
+// Update records bit in the selected context; the codec calls it after each
+// bit.
+func (h *HistoryBitModel) Update(bit uint32) {
+	s := &h.states[h.context]
+	h.probs.update(uint32(*s), bit)
+	*s = bitHistory.next[*s][bit]
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Encode_HistoryBitModel(bit uint32, M *HistoryBitModel) {
+	a.encodeBit(bit, SM__MaxProb-M.p1())
+	M.Update(bit)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_HistoryBitModel(M *HistoryBitModel) uint32 {
+	bit := a.decodeBit(SM__MaxProb - M.p1())
+	M.Update(bit)
+	return bit
+}
@@ -0,0 +1,64 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBitHistory(t *testing.T) {
+	if bitHistory.counts[0] != [2]uint8{0, 0} || bitHistory.states > 256 {
+		t.Fatalf("state 0 has counts %v, %d states", bitHistory.counts[0], bitHistory.states)
+	}
+	for s := 0; s < bitHistory.states; s++ {
+		for bit := uint32(0); bit < 2; bit++ {
+			next := bitHistory.next[s][bit]
+			if int(next) >= bitHistory.states {
+				t.Fatalf("state %d goes to unused state %d", s, next)
+			}
+			// The count of the bit seen grows unless it is at its bound, and
+			// the other count never grows.
+			n, m := bitHistory.counts[s], bitHistory.counts[next]
+			if m[1-bit] > n[1-bit] || (m[bit] <= n[bit] && m[bit] != historyBound(m[1-bit])) {
+				t.Errorf("state %d %v goes to %v after a %d", s, n, m, bit)
+			}
+		}
+	}
+}
+
+func TestHistoryBitModel(t *testing.T) {
+	const n = 200000
+
+	// Bits whose probability depends on the two bits before them.
+	src := initRandomBitSource()
+	src.SetSeed(20)
+	probability0 := []float64{0.9, 0.3, 0.6, 0.05}
+	data := make([]uint32, n)
+	context := make([]uint32, n)
+	for k := 2; k < n; k++ {
+		context[k] = data[k-2]<<1 | data[k-1]
+		src.SetProbability0(probability0[context[k]])
+		data[k] = uint32(src.Bit())
+	}
+
+	model := initHistoryBitModel(4)
+	checkBitCode(t, "two-bit contexts", model, data, func(k int) { model.SetContext(context[k]) })
+
+	// On a source that switches every 1000 bits, the histories save more than
+	// half of what AdaptiveBitModel spends, and lose little on a stationary
+	// source.
+	switching := nonstationaryBits(n, 1000)
+	if history, counted := costBits(initHistoryBitModel(1), switching, nil), adaptiveBitBits(DefaultAdaptation, switching); history >= counted/2 {
+		t.Errorf("switching bits: HistoryBitModel used %.0f bits, AdaptiveBitModel %.0f", history, counted)
+	}
+	stationary := nonstationaryBits(n, 0)
+	if history, counted := costBits(initHistoryBitModel(1), stationary, nil), adaptiveBitBits(DefaultAdaptation, stationary); history > 1.03*counted {
+		t.Errorf("stationary bits: HistoryBitModel used %.0f bits, AdaptiveBitModel %.0f", history, counted)
+	}
+
+	if _, err := NewHistoryBitModel(0); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("NewHistoryBitModel(0) error = %v, want ErrInvalidContext", err)
+	}
+	if err := model.TrySetContext(4); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("TrySetContext(4) error = %v, want ErrInvalidContext", err)
+	}
+}
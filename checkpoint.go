@@ -0,0 +1,112 @@
+package FastAC
+
+import "math"
+
+// CodecCheckpoint is the state of an ArithmeticCodec returned by Checkpoint.
+// It is a few words: rolling back never copies the code buffer.
+type CodecCheckpoint struct {
+	codec               *ArithmeticCodec
+	mode                Mode
+	base, value, length uint32
+	ac_pointer          []byte
+	overflow            bool
+
+	// A carry can add at most one to the code written before the checkpoint,
+	// turning its trailing 0xFF bytes into zeros and incrementing the byte
+	// before them. That byte, at carry_index (-1 if there is none), is all
+	// Rollback needs to undo it.
+	carry_index int
+	carry_byte  byte
+}
+
+// Checkpoint records the codec state so that everything coded afterwards can
+// be discarded with Rollback. Adaptive models must be saved separately, with
+// Clone or CopyFrom, and restored along with the codec. Stream encoders
+// cannot be checkpointed, as their output may already be written.
+func (a *ArithmeticCodec) Checkpoint() CodecCheckpoint {
+	cp, err := a.TryCheckpoint()
+	mustSucceed(err)
+	return cp
+}
+
+func (a *ArithmeticCodec) TryCheckpoint() (CodecCheckpoint, error) {
+	if a.mode == Undefined {
+		return CodecCheckpoint{}, codecError("Checkpoint", ErrWrongMode, "codec is not started")
+	}
+	if a.sink != nil {
+		return CodecCheckpoint{}, codecError("Checkpoint", ErrWrongMode, "cannot checkpoint a stream encoder")
+	}
+	cp := CodecCheckpoint{codec: a, mode: a.mode, base: a.base, value: a.value, length: a.length, ac_pointer: a.ac_pointer, overflow: a.overflow}
+	if a.mode == Encoder {
+		cp.carry_index = len(a.ac_pointer) - 1
+		for cp.carry_index >= 0 && a.ac_pointer[cp.carry_index] == 0xFF {
+			cp.carry_index--
+		}
+		if cp.carry_index >= 0 {
+			cp.carry_byte = a.ac_pointer[cp.carry_index]
+		}
+	}
+	return cp, nil
+}
+
+// Rollback returns the codec to a checkpoint taken since it was started. The
+// checkpoint stays valid, so several alternatives can be tried from it.
+func (a *ArithmeticCodec) Rollback(cp CodecCheckpoint) {
+	mustSucceed(a.TryRollback(cp))
+}
+
+func (a *ArithmeticCodec) TryRollback(cp CodecCheckpoint) error {
+	if cp.codec != a || cp.mode != a.mode {
+		return codecError("Rollback", ErrWrongMode, "checkpoint is not from this coding session")
+	}
+	a.base, a.value, a.length = cp.base, cp.value, cp.length
+	if a.mode == Decoder {
+		a.ac_pointer = cp.ac_pointer
+		return nil
+	}
+	if len(a.ac_pointer) < len(cp.ac_pointer) {
+		return codecError("Rollback", ErrWrongMode, "checkpoint is not from this coding session")
+	}
+	// An encoder that overflowed since the checkpoint dropped its output and
+	// its carries, so restoring the bytes below is still exact.
+	a.ac_pointer, a.overflow = a.ac_pointer[:len(cp.ac_pointer)], cp.overflow
+	for k := cp.carry_index + 1; k < len(a.ac_pointer); k++ {
+		a.ac_pointer[k] = 0xFF
+	}
+	if cp.carry_index >= 0 {
+		a.ac_pointer[cp.carry_index] = cp.carry_byte
+	}
+	return nil
+}
+
+// BitsSince returns the number of bits coded since the checkpoint, counting
+// the fraction of a bit held in the interval. For the encoder it is what the
+// symbols coded since cp add to the final code length, to within the few bits
+// StopEncoder adds.
+func (a *ArithmeticCodec) BitsSince(cp CodecCheckpoint) float64 {
+	bytes := len(a.ac_pointer) - len(cp.ac_pointer)
+	if a.mode == Decoder {
+		bytes = -bytes
+	}
+	return 8*float64(bytes) + math.Log2(float64(cp.length)) - math.Log2(float64(a.length))
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// CopyFrom sets the model to the state of src, without allocating, so a model
+// saved before a trial encoding can be restored after a Rollback.
+func (a *AdaptiveBitModel) CopyFrom(src *AdaptiveBitModel) {
+	*a = *src
+}
+
+// CopyFrom sets the model to the state of src, reusing its memory when the
+// alphabets have the same size.
+func (a *AdaptiveDataModel) CopyFrom(src *AdaptiveDataModel) {
+	if a.data_symbols != src.data_symbols {
+		*a = *src.Clone()
+		return
+	}
+	copy(a.distribution, src.distribution)
+	a.total_count, a.update_cycle, a.symbols_until_update = src.total_count, src.update_cycle, src.symbols_until_update
+	a.requested, a.adaptation = src.requested, src.adaptation
+}
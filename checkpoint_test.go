@@ -0,0 +1,76 @@
+package FastAC
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestArithmeticCodec_Rollback(t *testing.T) {
+	const n = 20000
+	rg := initRandomGenerator(14)
+	model, trial := initAdaptiveDataModel(64), initAdaptiveDataModel(64)
+	bit_model, bit_trial := initAdaptiveBitModel(), initAdaptiveBitModel()
+	reference, reference_bits := initAdaptiveDataModel(64), initAdaptiveBitModel()
+
+	// For each symbol, code three candidates and keep the cheapest. The 0xFF
+	// runs from PutBits make carries into code written before the checkpoint.
+	codec := initArithmeticCodec(4*n, nil)
+	kept := make([]uint32, n)
+	codec.StartEncoder()
+	for k := range kept {
+		trial.CopyFrom(model)
+		bit_trial.CopyFrom(bit_model)
+		cp := codec.Checkpoint()
+		best, best_bits := uint32(0), math.Inf(1)
+		for c := uint32(0); c < 3; c++ {
+			candidate := (rg.Integer(8) * rg.Integer(8)) % 64
+			codec.Encode_AdaptiveDataModel(candidate, model)
+			codec.PutBits(0xFF, 8)
+			codec.Encode_AdaptiveBitModel(candidate&1, bit_model)
+			if bits := codec.BitsSince(cp); bits < best_bits {
+				best, best_bits = candidate, bits
+			}
+			codec.Rollback(cp)
+			model.CopyFrom(trial)
+			bit_model.CopyFrom(bit_trial)
+		}
+		codec.Encode_AdaptiveDataModel(best, model)
+		codec.PutBits(0xFF, 8)
+		codec.Encode_AdaptiveBitModel(best&1, bit_model)
+		kept[k] = best
+	}
+	code_bytes := codec.StopEncoder()
+
+	// The code must match one made without any trials.
+	plain := initArithmeticCodec(4*n, nil)
+	plain.StartEncoder()
+	for _, s := range kept {
+		plain.Encode_AdaptiveDataModel(s, reference)
+		plain.PutBits(0xFF, 8)
+		plain.Encode_AdaptiveBitModel(s&1, reference_bits)
+	}
+	if plain_bytes := plain.StopEncoder(); string(plain.code_buffer[:plain_bytes]) != string(codec.code_buffer[:code_bytes]) {
+		t.Fatalf("code with rollbacks (%d bytes) differs from plain code (%d bytes)", code_bytes, plain_bytes)
+	}
+
+	// Decoders can roll back too.
+	reference.Reset()
+	codec.StartDecoder()
+	cp := codec.Checkpoint()
+	first := codec.Decode_AdaptiveDataModel(reference)
+	codec.Rollback(cp)
+	reference.Reset()
+	if again := codec.Decode_AdaptiveDataModel(reference); again != first || first != kept[0] {
+		t.Errorf("decoded %d after rollback, %d before, want %d", again, first, kept[0])
+	}
+	codec.StopDecoder()
+
+	if _, err := plain.TryCheckpoint(); !errors.Is(err, ErrWrongMode) {
+		t.Errorf("TryCheckpoint() on a stopped codec error = %v, want ErrWrongMode", err)
+	}
+	plain.StartEncoder()
+	if err := plain.TryRollback(cp); !errors.Is(err, ErrWrongMode) {
+		t.Errorf("TryRollback() with another codec's checkpoint error = %v, want ErrWrongMode", err)
+	}
+}
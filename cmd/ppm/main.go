@@ -0,0 +1,69 @@
+// Command ppm compresses and decompresses files with the PPM byte model.
+//
+//	ppm [-order n] [-escape c|d] input output
+//	ppm -d input output
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	FastAC "github.com/amaanq/FastAC-go"
+)
+
+func main() {
+	decompress := flag.Bool("d", false, "decompress input")
+	order := flag.Uint("order", 4, fmt.Sprintf("context order, 0 to %d", FastAC.PPM__MaxOrder))
+	escape := flag.String("escape", "d", "escape estimation, c (PPMC) or d (PPMD)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ppm [-order n] [-escape c|d] input output\n       ppm -d input output")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Arg(1), *decompress, *order, *escape); err != nil {
+		fmt.Fprintln(os.Stderr, "ppm:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, output string, decompress bool, order uint, escape string) error {
+	in, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+
+	var out []byte
+	if decompress {
+		out, err = FastAC.DecompressPPM(in)
+	} else {
+		method := FastAC.PPMD
+		switch escape {
+		case "c":
+			method = FastAC.PPMC
+		case "d":
+		default:
+			return fmt.Errorf("unknown escape method %q", escape)
+		}
+		if order > FastAC.PPM__MaxOrder {
+			return fmt.Errorf("order %d is above %d", order, FastAC.PPM__MaxOrder)
+		}
+		out, err = FastAC.CompressPPM(in, uint32(order), method)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, out, 0o644); err != nil {
+		return err
+	}
+
+	if !decompress && len(in) > 0 {
+		fmt.Fprintf(os.Stderr, "%d -> %d bytes (%.3f bits/byte)\n", len(in), len(out), 8*float64(len(out))/float64(len(in)))
+	}
+	return nil
+}
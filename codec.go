@@ -0,0 +1,110 @@
+package FastAC
+
+import (
+	"fmt"
+	"os"
+)
+
+// Codec is the method set shared by the codec versions, so coding loops can
+// be written once and the version chosen when the codec is built.
+type Codec interface {
+	SetBuffer(max_code_bytes uint32, user_buffer []byte)
+	TrySetBuffer(max_code_bytes uint32, user_buffer []byte) error
+
+	StartEncoder()
+	TryStartEncoder() error
+	StopEncoder() uint32
+	TryStopEncoder() (uint32, error)
+	WriteToFile(file *os.File) uint32
+	TryWriteToFile(file *os.File) (uint32, error)
+
+	StartDecoder()
+	TryStartDecoder() error
+	StopDecoder()
+	TryStopDecoder() error
+	ReadFromFile(file *os.File)
+	TryReadFromFile(file *os.File) error
+
+	PutBit(bit uint32)
+	GetBit() bool
+	PutBits(data, bits uint32)
+	GetBits(bits uint32) uint32
+
+	Encode_StaticBitModel(bit uint32, M *StaticBitModel)
+	Decode_StaticBitModel(M *StaticBitModel) uint32
+	Encode_AdaptiveBitModel(bit uint32, M *AdaptiveBitModel)
+	Decode_AdaptiveBitModel(M *AdaptiveBitModel) uint32
+	Encode_StaticDataModel(data uint32, M *StaticDataModel)
+	Decode_StaticDataModel(M *StaticDataModel) uint32
+	Encode_AdaptiveDataModel(data uint32, M *AdaptiveDataModel)
+	Decode_AdaptiveDataModel(M *AdaptiveDataModel) uint32
+}
+
+// NewCodec returns a codec of the given version; the buffer arguments are
+// those of NewArithmeticCodec. Int3232_Sorted differs from Int3232 only in its
+// model, SortedAdaptiveDataModel, so both get an *ArithmeticCodec.
+// FloatingPoint gives the exact but slow *ReferenceCodec.
+func NewCodec(version Version, max_code_bytes uint32, user_buffer []byte) (Codec, error) {
+	var codec Codec
+	var err error
+	switch version {
+	case Int3232, Int3232_Sorted:
+		codec, err = NewArithmeticCodec(max_code_bytes, user_buffer)
+	case Int3264:
+		codec, err = NewArithmeticCodec64(max_code_bytes, user_buffer)
+	case FloatingPoint:
+		codec, err = NewReferenceCodec(max_code_bytes, user_buffer)
+	default:
+		err = codecError("NewCodec", ErrInvalidVersion, version.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+func initCodec(version Version, max_code_bytes uint32, user_buffer []byte) Codec {
+	codec, err := NewCodec(version, max_code_bytes, user_buffer)
+	mustSucceed(err)
+	return codec
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// codeBuffer is the buffer handling shared by the codecs, with SetBuffer.
+type codeBuffer struct {
+	code_buffer, new_buffer []byte
+	buffer_size             uint32
+	mode                    Mode
+}
+
+func (c *codeBuffer) SetBuffer(max_code_bytes uint32, user_buffer []byte) {
+	mustSucceed(c.TrySetBuffer(max_code_bytes, user_buffer))
+}
+
+func (c *codeBuffer) TrySetBuffer(max_code_bytes uint32, user_buffer []byte) error {
+	if (max_code_bytes < 16 || max_code_bytes > 0x1000000) && user_buffer != nil {
+		return codecError("SetBuffer", ErrInvalidBufferSize, fmt.Sprint(max_code_bytes))
+	}
+	if user_buffer != nil && uint32(len(user_buffer)) < max_code_bytes {
+		return codecError("SetBuffer", ErrInvalidBufferSize, "user buffer shorter than max_code_bytes")
+	}
+	if c.mode != Undefined {
+		return codecError("SetBuffer", ErrWrongMode, "cannot set buffer while encoding or decoding")
+	}
+
+	if user_buffer != nil {
+		c.buffer_size = max_code_bytes
+		c.code_buffer = user_buffer
+		return nil
+	}
+
+	if max_code_bytes <= c.buffer_size {
+		return nil
+	}
+
+	c.buffer_size = max_code_bytes
+	c.new_buffer = make([]byte, c.buffer_size+16)
+	c.code_buffer = c.new_buffer
+	return nil
+}
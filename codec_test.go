@@ -9,6 +9,7 @@ import (
 const SimulTests = 1000000
 
 type TestResult struct {
+	version                        Version
 	alphabetSymbols                uint32
 	encoderTime, decoderTime       float64
 	entropy, bitsUsed, testSymbols float64
@@ -17,7 +18,7 @@ type TestResult struct {
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 // - - Implementations for testing encoder/decoder - - - - - - - - - - - - - -
 
-func EncodeStaticBitBuffer(bitBuffer []byte, model *StaticBitModel, encoder *ArithmeticCodec) uint32 {
+func EncodeStaticBitBuffer(bitBuffer []byte, model *StaticBitModel, encoder Codec) uint32 {
 	encoder.StartEncoder()
 	for k := 0; k < SimulTests; k++ {
 		encoder.Encode_StaticBitModel(uint32(bitBuffer[k]), model)
@@ -27,7 +28,7 @@ func EncodeStaticBitBuffer(bitBuffer []byte, model *StaticBitModel, encoder *Ari
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func DecodeStaticBitBuffer(bitBuffer []byte, model *StaticBitModel, decoder *ArithmeticCodec) {
+func DecodeStaticBitBuffer(bitBuffer []byte, model *StaticBitModel, decoder Codec) {
 	decoder.StartDecoder()
 	for k := 0; k < SimulTests; k++ {
 		bitBuffer[k] = byte(decoder.Decode_StaticBitModel(model))
@@ -37,7 +38,7 @@ func DecodeStaticBitBuffer(bitBuffer []byte, model *StaticBitModel, decoder *Ari
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func EncodeAdaptiveBitBuffer(bitBuffer []byte, model *AdaptiveBitModel, encoder *ArithmeticCodec) uint32 {
+func EncodeAdaptiveBitBuffer(bitBuffer []byte, model *AdaptiveBitModel, encoder Codec) uint32 {
 	encoder.StartEncoder()
 	for k := 0; k < SimulTests; k++ {
 		encoder.Encode_AdaptiveBitModel(uint32(bitBuffer[k]), model)
@@ -47,7 +48,7 @@ func EncodeAdaptiveBitBuffer(bitBuffer []byte, model *AdaptiveBitModel, encoder
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func DecodeAdaptiveBitBuffer(bitBuffer []byte, model *AdaptiveBitModel, decoder *ArithmeticCodec) {
+func DecodeAdaptiveBitBuffer(bitBuffer []byte, model *AdaptiveBitModel, decoder Codec) {
 	decoder.StartDecoder()
 	for k := 0; k < SimulTests; k++ {
 		bitBuffer[k] = byte(decoder.Decode_AdaptiveBitModel(model))
@@ -57,7 +58,27 @@ func DecodeAdaptiveBitBuffer(bitBuffer []byte, model *AdaptiveBitModel, decoder
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func EncodeStaticDataBuffer(dataBuffer []uint16, model *StaticDataModel, encoder *ArithmeticCodec) uint32 {
+func EncodeShiftBitBuffer(bitBuffer []byte, model *ShiftBitModel, encoder *ArithmeticCodec) uint32 {
+	encoder.StartEncoder()
+	for k := 0; k < SimulTests; k++ {
+		encoder.Encode_ShiftBitModel(uint32(bitBuffer[k]), model)
+	}
+	return 8 * encoder.StopEncoder()
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func DecodeShiftBitBuffer(bitBuffer []byte, model *ShiftBitModel, decoder *ArithmeticCodec) {
+	decoder.StartDecoder()
+	for k := 0; k < SimulTests; k++ {
+		bitBuffer[k] = byte(decoder.Decode_ShiftBitModel(model))
+	}
+	decoder.StopDecoder()
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func EncodeStaticDataBuffer(dataBuffer []uint16, model *StaticDataModel, encoder Codec) uint32 {
 	encoder.StartEncoder()
 	for k := 0; k < SimulTests; k++ {
 		encoder.Encode_StaticDataModel(uint32(dataBuffer[k]), model)
@@ -67,7 +88,7 @@ func EncodeStaticDataBuffer(dataBuffer []uint16, model *StaticDataModel, encoder
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func DecodeStaticDataBuffer(dataBuffer []uint16, model *StaticDataModel, decoder *ArithmeticCodec) {
+func DecodeStaticDataBuffer(dataBuffer []uint16, model *StaticDataModel, decoder Codec) {
 	decoder.StartDecoder()
 	for k := 0; k < SimulTests; k++ {
 		dataBuffer[k] = uint16(decoder.Decode_StaticDataModel(model))
@@ -77,7 +98,7 @@ func DecodeStaticDataBuffer(dataBuffer []uint16, model *StaticDataModel, decoder
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func EncodeAdaptiveDataBuffer(dataBuffer []uint16, model *AdaptiveDataModel, encoder *ArithmeticCodec) uint32 {
+func EncodeAdaptiveDataBuffer(dataBuffer []uint16, model *AdaptiveDataModel, encoder Codec) uint32 {
 	encoder.StartEncoder()
 	for k := 0; k < SimulTests; k++ {
 		encoder.Encode_AdaptiveDataModel(uint32(dataBuffer[k]), model)
@@ -87,7 +108,7 @@ func EncodeAdaptiveDataBuffer(dataBuffer []uint16, model *AdaptiveDataModel, enc
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func DecodeAdaptiveDataBuffer(dataBuffer []uint16, model *AdaptiveDataModel, decoder *ArithmeticCodec) {
+func DecodeAdaptiveDataBuffer(dataBuffer []uint16, model *AdaptiveDataModel, decoder Codec) {
 	decoder.StartDecoder()
 	for k := 0; k < SimulTests; k++ {
 		dataBuffer[k] = uint16(decoder.Decode_AdaptiveDataModel(model))
@@ -115,14 +136,14 @@ func FillDataBuffer(src *RandomDataSource, dataBuffer []uint16) {
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func DisplayResults(first, adaptive bool, pr *TestResult, sourceTime float64) {
-	if adaptive {
-		fmt.Println(" Test with adaptive model")
+func DisplayResults(first bool, model string, pr *TestResult, sourceTime float64) {
+	if model != "static" {
+		fmt.Printf(" Test with %s model\n", model)
 	} else {
 		if first {
 			fmt.Println("\n=========================================================================")
 		}
-		fmt.Printf(" Test with static model\n\n")
+		fmt.Printf(" Test with static model (%v codec)\n\n", pr.version)
 	}
 
 	fmt.Printf(" Random  data generated in %5.2f seconds\n", sourceTime)
@@ -160,13 +181,13 @@ func DisplayResults(first, adaptive bool, pr *TestResult, sourceTime float64) {
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func BinaryBenchmark(num_cycles int) {
+func BinaryBenchmark(version Version, num_cycles int) {
 	num_simulations := 10
 	entropy, entropy_increment := 0.1, 0.1
 
-	result := new(TestResult)
+	result := &TestResult{version: version}
 	src := initRandomBitSource()
-	codec := initArithmeticCodec(SimulTests>>2, nil)
+	codec := initCodec(version, SimulTests>>2, nil)
 	static_model := initStaticBitModel()
 	adaptive_model := initAdaptiveBitModel()
 	encoder_time, decoder_time, source_time := new(Chronometer), new(Chronometer), new(Chronometer)
@@ -175,8 +196,18 @@ func BinaryBenchmark(num_cycles int) {
 	source_bits := make([]byte, 2*SimulTests)
 	decoded_bits := make([]byte, 2*SimulTests)
 
+	// The shift models are only coded by ArithmeticCodec.
+	models := []string{"static", "adaptive"}
+	shift_model := initShiftBitModel(5)
+	dual_shift_model, err := NewDualRateShiftBitModel(4, 7)
+	mustSucceed(err)
+	shift_codec, is_arithmetic := codec.(*ArithmeticCodec)
+	if is_arithmetic {
+		models = append(models, "shift (rate 5)", "shift (rates 4 and 7)")
+	}
+
 	for simul := 0; simul < num_simulations; simul++ {
-		for pass := 0; pass <= 1; pass++ {
+		for pass := range models {
 			src.SetEntropy(entropy)
 			src.SetSeed(1839304 + 2017*uint32(simul))
 
@@ -203,7 +234,7 @@ func BinaryBenchmark(num_cycles int) {
 					decoder_time.Start("")
 					DecodeStaticBitBuffer(decoded_bits, static_model, codec)
 					decoder_time.Stop()
-				} else {
+				} else if pass == 1 {
 					adaptive_model.reset()
 					encoder_time.Start("")
 					code_bits = EncodeAdaptiveBitBuffer(source_bits, adaptive_model, codec)
@@ -213,6 +244,20 @@ func BinaryBenchmark(num_cycles int) {
 					decoder_time.Start("")
 					DecodeAdaptiveBitBuffer(decoded_bits, adaptive_model, codec)
 					decoder_time.Stop()
+				} else {
+					model := shift_model
+					if pass == 3 {
+						model = dual_shift_model
+					}
+					model.Reset()
+					encoder_time.Start("")
+					code_bits = EncodeShiftBitBuffer(source_bits, model, shift_codec)
+					encoder_time.Stop()
+
+					model.Reset()
+					decoder_time.Start("")
+					DecodeShiftBitBuffer(decoded_bits, model, shift_codec)
+					decoder_time.Stop()
 				}
 
 				result.testSymbols += float64(SimulTests)
@@ -227,7 +272,7 @@ func BinaryBenchmark(num_cycles int) {
 
 			result.encoderTime = encoder_time.Read().Seconds()
 			result.decoderTime = decoder_time.Read().Seconds()
-			DisplayResults(simul == 0, pass != 0, result, source_time.Read().Seconds())
+			DisplayResults(simul == 0, models[pass], result, source_time.Read().Seconds())
 		}
 		entropy += entropy_increment
 	}
@@ -235,7 +280,7 @@ func BinaryBenchmark(num_cycles int) {
 
 // - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
 
-func GeneralBenchmark(data_symbols, num_cycles uint32) {
+func GeneralBenchmark(version Version, data_symbols, num_cycles uint32) {
 	var entropy, entropy_increment float64
 	if data_symbols <= 8 {
 		entropy = 0.2
@@ -252,9 +297,9 @@ func GeneralBenchmark(data_symbols, num_cycles uint32) {
 
 	num_simulations := int(1 + ((math.Log(float64(data_symbols))/math.Log(2.0) - entropy) / entropy_increment))
 
-	result := new(TestResult)
+	result := &TestResult{version: version}
 	src := initRandomDataSource()
-	codec := initArithmeticCodec(SimulTests<<1, nil)
+	codec := initCodec(version, SimulTests<<1, nil)
 	static_model := initStaticDataModel()
 	adaptive_model := initAdaptiveDataModel(data_symbols)
 	encoder_time, decoder_time, source_time := new(Chronometer), new(Chronometer), new(Chronometer)
@@ -264,9 +309,10 @@ func GeneralBenchmark(data_symbols, num_cycles uint32) {
 	decoded_data := make([]uint16, SimulTests)
 
 	adaptive_model.SetAlphabet(data_symbols)
+	models := []string{"static", "adaptive"}
 
 	for simul := 0; simul < int(num_simulations); simul++ {
-		for pass := 0; pass <= 1; pass++ {
+		for pass := range models {
 			src.SetTruncatedGeometric(data_symbols, entropy)
 			src.SetSeed(8315739 + 1031*uint32(simul) + 11*uint32(data_symbols))
 
@@ -317,7 +363,7 @@ func GeneralBenchmark(data_symbols, num_cycles uint32) {
 
 			result.encoderTime = encoder_time.Read().Seconds()
 			result.decoderTime = decoder_time.Read().Seconds()
-			DisplayResults(simul == 0, pass != 0, result, source_time.Read().Seconds())
+			DisplayResults(simul == 0, models[pass], result, source_time.Read().Seconds())
 		}
 		entropy += entropy_increment
 	}
@@ -327,9 +373,11 @@ func Test(t *testing.T) {
 	num_symbols := 3
 	total_cycles := 10
 
-	if num_symbols == 2 {
-		BinaryBenchmark(num_symbols)
-	} else {
-		GeneralBenchmark(uint32(num_symbols), uint32(total_cycles))
+	for _, version := range []Version{Int3232, Int3264} {
+		if num_symbols == 2 {
+			BinaryBenchmark(version, num_symbols)
+		} else {
+			GeneralBenchmark(version, uint32(num_symbols), uint32(total_cycles))
+		}
 	}
 }
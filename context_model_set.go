@@ -0,0 +1,187 @@
+package FastAC
+
+import "fmt"
+
+// ContextKeys says how a ContextModelSet finds the model of a context.
+type ContextKeys uint32
+
+const (
+	// IndexedContexts keys models by contexts 0 to N-1.
+	IndexedContexts ContextKeys = iota
+	// HashedContexts accepts any 32-bit context and hashes it to one of N
+	// models; contexts that collide share a model.
+	HashedContexts
+)
+
+const CM__MaxContexts = 1 << 24
+
+// ContextModelSet owns one adaptive model per context, either bit models or
+// data models with a common alphabet, and codes each symbol with the model of
+// its context. Models are allocated the first time their context is coded,
+// so a large set costs little until it is used.
+//
+// The set codes with the codec it was made for; the encoder and decoder must
+// each use their own set, or Reset it between encoding and decoding.
+type ContextModelSet struct {
+	codec        *ArithmeticCodec
+	keys         ContextKeys
+	contexts     uint32
+	data_symbols uint32 // 0 for a set of bit models
+	adaptation   Adaptation
+	bit_models   map[uint32]*AdaptiveBitModel // by slot, as they are first coded
+	data_models  map[uint32]*AdaptiveDataModel
+}
+
+// NewBitModelSet returns a set of contexts AdaptiveBitModels, from 1 to
+// CM__MaxContexts, that learn as set by ad.
+func NewBitModelSet(codec *ArithmeticCodec, keys ContextKeys, contexts uint32, ad Adaptation) (*ContextModelSet, error) {
+	c, err := newContextModelSet("NewBitModelSet", codec, keys, contexts, ad)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := NewAdaptiveBitModelWith(ad); err != nil {
+		return nil, err
+	}
+	c.bit_models = make(map[uint32]*AdaptiveBitModel)
+	return c, nil
+}
+
+// NewDataModelSet returns a set of contexts AdaptiveDataModels, from 1 to
+// CM__MaxContexts, each for data_symbols symbols and learning as set by ad.
+func NewDataModelSet(codec *ArithmeticCodec, keys ContextKeys, contexts, data_symbols uint32, ad Adaptation) (*ContextModelSet, error) {
+	c, err := newContextModelSet("NewDataModelSet", codec, keys, contexts, ad)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := NewAdaptiveDataModelWith(data_symbols, ad); err != nil {
+		return nil, err
+	}
+	c.data_symbols = data_symbols
+	c.data_models = make(map[uint32]*AdaptiveDataModel)
+	return c, nil
+}
+
+func newContextModelSet(op string, codec *ArithmeticCodec, keys ContextKeys, contexts uint32, ad Adaptation) (*ContextModelSet, error) {
+	if codec == nil {
+		return nil, codecError(op, ErrWrongMode, "no codec")
+	}
+	if keys != IndexedContexts && keys != HashedContexts {
+		return nil, codecError(op, ErrInvalidContext, fmt.Sprintf("keys %d", keys))
+	}
+	if contexts < 1 || contexts > CM__MaxContexts {
+		return nil, codecError(op, ErrInvalidContext, fmt.Sprintf("%d contexts", contexts))
+	}
+	return &ContextModelSet{codec: codec, keys: keys, contexts: contexts, adaptation: ad}, nil
+}
+
+// Contexts returns the number of models the set can hold.
+func (c *ContextModelSet) Contexts() uint32 {
+	return c.contexts
+}
+
+// Allocated returns the number of models allocated so far.
+func (c *ContextModelSet) Allocated() int {
+	return len(c.bit_models) + len(c.data_models)
+}
+
+// Reset returns every allocated model to its initial state, keeping its
+// memory.
+func (c *ContextModelSet) Reset() {
+	for _, M := range c.bit_models {
+		M.reset()
+	}
+	for _, M := range c.data_models {
+		M.Reset()
+	}
+}
+
+// slot returns the index of the model of context.
+func (c *ContextModelSet) slot(op string, context uint32) (uint32, error) {
+	if c.keys == HashedContexts {
+		return uint32((uint64(context*0x9E3779B1) * uint64(c.contexts)) >> 32), nil
+	}
+	if context >= c.contexts {
+		return 0, codecError(op, ErrInvalidContext, fmt.Sprintf("context %d of %d", context, c.contexts))
+	}
+	return context, nil
+}
+
+func (c *ContextModelSet) bitModel(op string, context uint32) (*AdaptiveBitModel, error) {
+	k, err := c.slot(op, context)
+	if err != nil {
+		return nil, err
+	}
+	M := c.bit_models[k]
+	if M == nil {
+		M, _ = NewAdaptiveBitModelWith(c.adaptation)
+		c.bit_models[k] = M
+	}
+	return M, nil
+}
+
+func (c *ContextModelSet) dataModel(op string, context uint32) (*AdaptiveDataModel, error) {
+	k, err := c.slot(op, context)
+	if err != nil {
+		return nil, err
+	}
+	M := c.data_models[k]
+	if M == nil {
+		M, _ = NewAdaptiveDataModelWith(c.data_symbols, c.adaptation)
+		c.data_models[k] = M
+	}
+	return M, nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// EncodeIn codes symbol, a bit or a data symbol, with the model of context.
+func (c *ContextModelSet) EncodeIn(context, symbol uint32) {
+	mustSucceed(c.TryEncodeIn(context, symbol))
+}
+
+// TryEncodeIn fails with ErrInvalidSymbol, before coding anything, for a
+// symbol the models cannot code: above 1 in a bit set, or not below the
+// number of data symbols.
+func (c *ContextModelSet) TryEncodeIn(context, symbol uint32) error {
+	if c.bit_models != nil && symbol > 1 || c.bit_models == nil && symbol >= c.data_symbols {
+		return codecError("EncodeIn", ErrInvalidSymbol, fmt.Sprintf("symbol %d", symbol))
+	}
+	if c.bit_models != nil {
+		M, err := c.bitModel("EncodeIn", context)
+		if err != nil {
+			return err
+		}
+		c.codec.Encode_AdaptiveBitModel(symbol, M)
+		return nil
+	}
+	M, err := c.dataModel("EncodeIn", context)
+	if err != nil {
+		return err
+	}
+	c.codec.Encode_AdaptiveDataModel(symbol, M)
+	return nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// DecodeIn decodes a symbol with the model of context.
+func (c *ContextModelSet) DecodeIn(context uint32) uint32 {
+	symbol, err := c.TryDecodeIn(context)
+	mustSucceed(err)
+	return symbol
+}
+
+func (c *ContextModelSet) TryDecodeIn(context uint32) (uint32, error) {
+	if c.bit_models != nil {
+		M, err := c.bitModel("DecodeIn", context)
+		if err != nil {
+			return 0, err
+		}
+		return c.codec.Decode_AdaptiveBitModel(M), nil
+	}
+	M, err := c.dataModel("DecodeIn", context)
+	if err != nil {
+		return 0, err
+	}
+	return c.codec.Decode_AdaptiveDataModel(M), nil
+}
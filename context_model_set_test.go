@@ -0,0 +1,152 @@
+package FastAC
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestContextModelSet(t *testing.T) {
+	text := generatedText(50000, 23)
+	codec := initArithmeticCodec(uint32(len(text)), nil)
+
+	// Order 1 with one model per previous byte, order 2 with the two previous
+	// bytes hashed into 4096 models.
+	order1, err := NewDataModelSet(codec, IndexedContexts, 256, 256, DefaultAdaptation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order2, err := NewDataModelSet(codec, HashedContexts, 4096, 256, DefaultAdaptation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := func(k int, order int) uint32 {
+		c := uint32(0)
+		for j := k - order; j < k; j++ {
+			if j >= 0 {
+				c = c<<8 | uint32(text[j])
+			}
+		}
+		return c
+	}
+
+	sizes := map[int]uint32{}
+	for _, order := range []int{1, 2} {
+		set := order1
+		if order == 2 {
+			set = order2
+		}
+		codec.StartEncoder()
+		for k, c := range text {
+			set.EncodeIn(context(k, order), uint32(c))
+		}
+		sizes[order] = codec.StopEncoder()
+
+		set.Reset()
+		allocated := set.Allocated()
+		codec.StartDecoder()
+		for k, c := range text {
+			if got := set.DecodeIn(context(k, order)); got != uint32(c) {
+				t.Fatalf("order %d: byte %d decoded as %d, want %d", order, k, got, c)
+			}
+		}
+		codec.StopDecoder()
+		if set.Allocated() != allocated {
+			t.Errorf("order %d: decoding allocated %d models after %d", order, set.Allocated(), allocated)
+		}
+	}
+
+	// Only the contexts seen are allocated: the text has fewer than 64
+	// distinct bytes.
+	if n := order1.Allocated(); n < 20 || n > 64 {
+		t.Errorf("order 1 allocated %d models", n)
+	}
+	codec.StartEncoder()
+	order0 := initAdaptiveDataModel(256)
+	for _, c := range text {
+		codec.Encode_AdaptiveDataModel(uint32(c), order0)
+	}
+	size0 := codec.StopEncoder()
+	// Order 2 has too many contexts for this little text to pay for learning
+	// 256 symbols in each, so only order 1 must gain.
+	if sizes[1] >= size0 {
+		t.Errorf("coded %d bytes of text in %d bytes with order 0, %d with order 1", len(text), size0, sizes[1])
+	}
+
+	// A bit set conditions each bit on the one before it, which a single
+	// model cannot see.
+	bits, err := NewBitModelSet(codec, IndexedContexts, 2, DefaultAdaptation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := initRandomBitSource()
+	src.SetSeed(23)
+	data := make([]uint32, 20000)
+	for k := 1; k < len(data); k++ {
+		src.SetProbability0([]float64{0.9, 0.2}[data[k-1]])
+		data[k] = uint32(src.Bit())
+	}
+	previous := func(k int) uint32 {
+		if k == 0 {
+			return 0
+		}
+		return data[k-1]
+	}
+	codec.StartEncoder()
+	for k, bit := range data {
+		bits.EncodeIn(previous(k), bit)
+	}
+	conditioned := codec.StopEncoder()
+	bits.Reset()
+	codec.StartDecoder()
+	for k, bit := range data {
+		if got := bits.DecodeIn(previous(k)); got != bit {
+			t.Fatalf("bit %d decoded as %d, want %d", k, got, bit)
+		}
+	}
+	codec.StopDecoder()
+	codec.StartEncoder()
+	single := initAdaptiveBitModel()
+	for _, bit := range data {
+		codec.Encode_AdaptiveBitModel(bit, single)
+	}
+	if plain := codec.StopEncoder(); 4*conditioned > 3*plain {
+		t.Errorf("coded %d bits in %d bytes with the previous bit as context, %d with one model", len(data), conditioned, plain)
+	}
+
+	if err := order1.TryEncodeIn(256, 0); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("TryEncodeIn(256) error = %v, want ErrInvalidContext", err)
+	}
+	// Symbols outside the alphabet are rejected before reaching the codec.
+	codec.StartEncoder()
+	small, _ := NewDataModelSet(codec, IndexedContexts, 4, 8, DefaultAdaptation)
+	if err := small.TryEncodeIn(0, 8); !errors.Is(err, ErrInvalidSymbol) {
+		t.Errorf("data set TryEncodeIn(0, 8) error = %v, want ErrInvalidSymbol", err)
+	}
+	if err := bits.TryEncodeIn(0, 2); !errors.Is(err, ErrInvalidSymbol) {
+		t.Errorf("bit set TryEncodeIn(0, 2) error = %v, want ErrInvalidSymbol", err)
+	}
+	if n := small.Allocated(); n != 0 {
+		t.Errorf("rejected symbols allocated %d models", n)
+	}
+	codec.StopEncoder()
+
+	// The largest set costs nothing until its contexts are coded.
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	largest, err := NewDataModelSet(codec, IndexedContexts, CM__MaxContexts, 256, DefaultAdaptation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if grown := after.TotalAlloc - before.TotalAlloc; grown > 1<<16 {
+		t.Errorf("a set of %d contexts allocated %d bytes before coding", largest.Contexts(), grown)
+	}
+
+	if _, err := NewBitModelSet(codec, IndexedContexts, 0, DefaultAdaptation); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("NewBitModelSet(0 contexts) error = %v, want ErrInvalidContext", err)
+	}
+	if _, err := NewDataModelSet(codec, HashedContexts, 16, 1, DefaultAdaptation); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("NewDataModelSet(1 symbol) error = %v, want ErrInvalidAlphabet", err)
+	}
+}
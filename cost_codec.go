@@ -0,0 +1,179 @@
+package FastAC
+
+import "math"
+
+// CostCodec has the Encode_ methods of ArithmeticCodec but writes nothing: it
+// adds up the ideal cost, -log2 p, of each symbol under the model's current
+// distribution, and updates adaptive models exactly as the encoder does. The
+// total is what an ArithmeticCodec would spend coding the same calls, less
+// its small rounding overhead and the bytes StopEncoder adds.
+type CostCodec struct {
+	bits float64
+}
+
+func NewCostCodec() *CostCodec {
+	return new(CostCodec)
+}
+
+// Bits returns the cost of everything coded since the codec was made or
+// Reset.
+func (a *CostCodec) Bits() float64 {
+	return a.bits
+}
+
+func (a *CostCodec) Reset() {
+	a.bits = 0
+}
+
+func (a *CostCodec) PutBit(bit uint32) {
+	a.bits++
+}
+
+func (a *CostCodec) PutBits(data, bits uint32) {
+	a.bits += float64(bits)
+}
+
+func (a *CostCodec) Encode_StaticBitModel(bit uint32, M *StaticBitModel) {
+	a.bits += M.Cost(bit)
+}
+
+func (a *CostCodec) Encode_AdaptiveBitModel(bit uint32, M *AdaptiveBitModel) {
+	a.bits += M.Cost(bit)
+	if bit == 0 {
+		M.bit_0_count++
+	}
+	M.bits_until_update--
+	if M.bits_until_update == 0 {
+		M.Update()
+	}
+}
+
+func (a *CostCodec) Encode_ShiftBitModel(bit uint32, M *ShiftBitModel) {
+	a.bits += M.Cost(bit)
+	M.Update(bit)
+}
+
+func (a *CostCodec) Encode_HistoryBitModel(bit uint32, M *HistoryBitModel) {
+	a.bits += M.Cost(bit)
+	M.Update(bit)
+}
+
+// Encode_AdaptiveBitModelAPM adds the cost of bit under M refined by A, and
+// updates both.
+func (a *CostCodec) Encode_AdaptiveBitModelAPM(bit uint32, M *AdaptiveBitModel, A *APM) {
+	a.bits += widthCost(shiftWidth(bit, A.refineBit0(M)), SM__LengthShift)
+	A.Update(bit)
+	adaptiveBitPredictor{M}.Update(bit)
+}
+
+// Encode_BitPredictor adds the cost of bit under P's prediction and updates P.
+func (a *CostCodec) Encode_BitPredictor(bit uint32, P BitPredictor) {
+	p := P.P()
+	if bit == 0 {
+		p = MX__MaxProb - p
+	}
+	a.bits += widthCost(p, MX__ProbBits)
+	P.Update(bit)
+}
+
+func (a *CostCodec) Encode_StaticDataModel(data uint32, M *StaticDataModel) {
+	a.bits += M.Cost(data)
+}
+
+func (a *CostCodec) Encode_AdaptiveDataModel(data uint32, M *AdaptiveDataModel) {
+	a.bits += M.Cost(data)
+	M.symbol_count[data]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update(true)
+	}
+}
+
+func (a *CostCodec) Encode_SortedAdaptiveDataModel(data uint32, M *SortedAdaptiveDataModel) {
+	a.bits += M.Cost(data)
+	M.symbol_count[data]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update()
+	}
+}
+
+func (a *CostCodec) Encode_LargeAdaptiveDataModel(data uint32, M *LargeAdaptiveDataModel) {
+	a.bits += M.Cost(data)
+	M.Update(data)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Symbol costs  - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// bitWidth returns the part of BM__MaxCount given to bit.
+func bitWidth(bit, bit_0_prob uint32) uint32 {
+	if bit == 0 {
+		return bit_0_prob
+	}
+	return BM__MaxCount - bit_0_prob
+}
+
+// shiftWidth returns the part of SM__MaxProb given to bit.
+func shiftWidth(bit, prob0 uint32) uint32 {
+	if bit == 0 {
+		return prob0
+	}
+	return SM__MaxProb - prob0
+}
+
+// dataWidth returns the part of DM__MaxCount given to the symbol at index k
+// of a distribution; the last symbol gets what the others leave.
+func dataWidth(distribution []uint32, last_symbol, k uint32) uint32 {
+	if k == last_symbol {
+		return DM__MaxCount - distribution[k]
+	}
+	return distribution[k+1] - distribution[k]
+}
+
+func widthCost(width uint32, shift uint) float64 {
+	return float64(shift) - math.Log2(float64(width))
+}
+
+// Cost returns the bits needed to code bit with the model: -log2 of its
+// probability.
+func (s *StaticBitModel) Cost(bit uint32) float64 {
+	return widthCost(bitWidth(bit, s.bit_0_prob), BM__LengthShift)
+}
+
+// Cost returns the bits needed to code bit with the model's current
+// probabilities.
+func (a *AdaptiveBitModel) Cost(bit uint32) float64 {
+	return widthCost(bitWidth(bit, a.bit_0_prob), BM__LengthShift)
+}
+
+// Cost returns the bits needed to code bit with the model's current
+// probability.
+func (s *ShiftBitModel) Cost(bit uint32) float64 {
+	return widthCost(shiftWidth(bit, s.prob0()), SM__LengthShift)
+}
+
+// Cost returns the bits needed to code bit in the selected context.
+func (h *HistoryBitModel) Cost(bit uint32) float64 {
+	return widthCost(shiftWidth(bit, SM__MaxProb-h.p1()), SM__LengthShift)
+}
+
+// Cost returns the bits needed to code data with the model; +Inf for a
+// symbol given zero probability.
+func (sdm *StaticDataModel) Cost(data uint32) float64 {
+	return widthCost(dataWidth(sdm.distribution, sdm.last_symbol, data), DM__LengthShift)
+}
+
+// Cost returns the bits needed to code data with the model's current
+// distribution.
+func (a *AdaptiveDataModel) Cost(data uint32) float64 {
+	return widthCost(dataWidth(a.distribution, a.last_symbol, data), DM__LengthShift)
+}
+
+func (a *SortedAdaptiveDataModel) Cost(data uint32) float64 {
+	return widthCost(dataWidth(a.distribution, a.last_symbol, a.rank[data]), DM__LengthShift)
+}
+
+func (a *LargeAdaptiveDataModel) Cost(data uint32) float64 {
+	return math.Log2(float64(a.total_count)) - math.Log2(float64(a.symbol_count[data]))
+}
@@ -0,0 +1,90 @@
+package FastAC
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCostCodec(t *testing.T) {
+	const n = 100000
+	rg := initRandomGenerator(15)
+	data := make([]uint32, n)
+	for k := range data {
+		data[k] = rg.Integer(20) * rg.Integer(20)
+	}
+	static_data := initStaticDataModel()
+	static_data.SetDistribution(400, nil)
+	static_bit := initStaticBitModel()
+	static_bit.SetProbability0(0.8)
+
+	type models struct {
+		bit    *AdaptiveBitModel
+		data   *AdaptiveDataModel
+		sorted *SortedAdaptiveDataModel
+		large  *LargeAdaptiveDataModel
+	}
+	newModels := func() models {
+		return models{initAdaptiveBitModel(), initAdaptiveDataModel(400), initSortedAdaptiveDataModel(400), initLargeAdaptiveDataModel(400)}
+	}
+
+	counted, coded := newModels(), newModels()
+	cost := NewCostCodec()
+	codec := initArithmeticCodec(8*n, nil)
+	codec.StartEncoder()
+	for k, s := range data {
+		bit := s & 1
+		switch k % 6 {
+		case 0:
+			cost.Encode_AdaptiveBitModel(bit, counted.bit)
+			codec.Encode_AdaptiveBitModel(bit, coded.bit)
+		case 1:
+			cost.Encode_StaticBitModel(bit, static_bit)
+			codec.Encode_StaticBitModel(bit, static_bit)
+		case 2:
+			cost.Encode_AdaptiveDataModel(s, counted.data)
+			codec.Encode_AdaptiveDataModel(s, coded.data)
+		case 3:
+			cost.Encode_SortedAdaptiveDataModel(s, counted.sorted)
+			codec.Encode_SortedAdaptiveDataModel(s, coded.sorted)
+		case 4:
+			cost.Encode_LargeAdaptiveDataModel(s, counted.large)
+			codec.Encode_LargeAdaptiveDataModel(s, coded.large)
+		default:
+			cost.Encode_StaticDataModel(s, static_data)
+			codec.Encode_StaticDataModel(s, static_data)
+			cost.PutBits(s, 9)
+			codec.PutBits(s, 9)
+		}
+	}
+	bits := 8 * float64(codec.StopEncoder())
+	if bits < cost.Bits() || bits > 1.001*cost.Bits()+32 {
+		t.Errorf("ArithmeticCodec used %.0f bits, CostCodec counted %.1f", bits, cost.Bits())
+	}
+
+	// Adaptive models must have learned the same thing.
+	want, _ := coded.data.MarshalBinary()
+	got, _ := counted.data.MarshalBinary()
+	if string(got) != string(want) {
+		t.Errorf("AdaptiveDataModel state differs after CostCodec")
+	}
+	if *counted.bit != *coded.bit {
+		t.Errorf("AdaptiveBitModel state differs after CostCodec")
+	}
+	for s := uint32(0); s < 400; s++ {
+		if a, b := counted.sorted.Cost(s), coded.sorted.Cost(s); a != b {
+			t.Fatalf("SortedAdaptiveDataModel.Cost(%d) = %v, coded model %v", s, a, b)
+		}
+		if a, b := counted.large.Cost(s), coded.large.Cost(s); a != b {
+			t.Fatalf("LargeAdaptiveDataModel.Cost(%d) = %v, coded model %v", s, a, b)
+		}
+	}
+
+	if c := static_bit.Cost(1); math.Abs(c-math.Log2(5)) > 1e-3 {
+		t.Errorf("StaticBitModel.Cost(1) = %v, want about %v", c, math.Log2(5))
+	}
+	zeros := initStaticDataModel()
+	zeros.SetFrequenciesWithZeros([]uint32{1, 0, 1})
+	if c := zeros.Cost(1); !math.IsInf(c, 1) {
+		t.Errorf("Cost of a zero probability symbol = %v, want +Inf", c)
+	}
+}
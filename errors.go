@@ -0,0 +1,65 @@
+package FastAC
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned (wrapped in a *CodecError) by the error-returning
+// API. Use errors.Is to test for them.
+var (
+	ErrBufferOverflow     = errors.New("code buffer overflow")
+	ErrInvalidBufferSize  = errors.New("invalid codec buffer size")
+	ErrInvalidProbability = errors.New("invalid probability")
+	ErrInvalidAlphabet    = errors.New("invalid number of data symbols")
+	ErrWrongMode          = errors.New("wrong codec mode")
+	ErrCorruptInput       = errors.New("corrupt input")
+	ErrInvalidVersion     = errors.New("unsupported codec version")
+	ErrInvalidAdaptation  = errors.New("invalid adaptation settings")
+	ErrInvalidContext     = errors.New("invalid context")
+	ErrInvalidInputs      = errors.New("invalid number of mixer inputs")
+	ErrInvalidMemory      = errors.New("invalid memory budget")
+	ErrInvalidSymbol      = errors.New("symbol outside the alphabet")
+)
+
+// ErrNeedInput is returned as is, without a *CodecError, by PushDecoder when
+// it cannot decode the next symbol until more code bytes are written to it.
+var ErrNeedInput = errors.New("need more input")
+
+// CodecError records the operation that failed and why. Err is one of the
+// sentinel errors above, or the I/O error that stopped a file operation.
+type CodecError struct {
+	Op     string // method that failed, e.g. "SetBuffer"
+	Detail string // optional extra context
+	Err    error
+}
+
+func (e *CodecError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("arithmetic coding error: %s: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("arithmetic coding error: %s: %v (%s)", e.Op, e.Err, e.Detail)
+}
+
+func (e *CodecError) Unwrap() error {
+	return e.Err
+}
+
+func codecError(op string, err error, detail string) error {
+	return &CodecError{Op: op, Detail: detail, Err: err}
+}
+
+// Coding a symbol given zero probability by SetFrequenciesWithZeros would
+// leave the codec with an empty interval, or for the last symbol with the
+// rounding remainder of the others, so the encoders reject it.
+func errZeroProbability(op string, data uint32) error {
+	return codecError(op, ErrInvalidProbability, fmt.Sprintf("symbol %d has zero probability", data))
+}
+
+// mustSucceed is the panic-compatible layer used by the methods that predate
+// the error-returning API.
+func mustSucceed(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
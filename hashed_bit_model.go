@@ -0,0 +1,138 @@
+package FastAC
+
+import "fmt"
+
+const (
+	HM__BucketSize = 16      // bytes of a bucket: a check byte and 15 bit histories
+	HM__MinMemory  = 1 << 10 // smallest memory budget of a HashedBitModel
+	HM__MaxMemory  = 1 << 30
+)
+
+// HashedBitModel keeps bit histories for contexts too many to index, such as
+// the last four bytes, in a hash table of fixed size, as lpaq does. A context
+// is hashed to a bucket of 16 bytes: a check byte mixed from the whole hash,
+// to tell apart contexts that share buckets, and the histories of the 15 nodes
+// of a binary tree of 4 bits, the bits coded after the context. Byte-oriented
+// coders select a context at each nibble, hashing in the first nibble for the
+// second.
+//
+// A context may be stored in one of three buckets. When none holds its check
+// byte, the one with the shortest history in its first node is cleared and
+// taken, so contexts seen often survive floods of new ones. Lookups depend
+// only on the contexts and bits coded, so encoder and decoder stay in step.
+//
+// Probabilities are learned per history over the whole table, as in
+// HistoryBitModel. The model is a BitPredictor, for mixing with other orders.
+type HashedBitModel struct {
+	table  []uint8
+	mask   uint32 // bucket numbers
+	probs  stateMap
+	bucket uint32 // offset of the selected bucket
+	node   uint32 // 1 to 15, the node of the next bit in the bucket's tree
+}
+
+// NewHashedBitModel returns a model that uses about memory bytes, from
+// HM__MinMemory to HM__MaxMemory, rounded down to a power of two.
+func NewHashedBitModel(memory uint32) (*HashedBitModel, error) {
+	if memory < HM__MinMemory || memory > HM__MaxMemory {
+		return nil, codecError("NewHashedBitModel", ErrInvalidMemory, fmt.Sprintf("%d bytes", memory))
+	}
+	buckets := uint32(HM__MinMemory / HM__BucketSize)
+	for 2*buckets*HM__BucketSize <= memory {
+		buckets *= 2
+	}
+	h := &HashedBitModel{table: make([]uint8, buckets*HM__BucketSize), mask: buckets - 1, probs: newStateMap(1)}
+	h.SetContext(0)
+	return h, nil
+}
+
+func initHashedBitModel(memory uint32) *HashedBitModel {
+	h, err := NewHashedBitModel(memory)
+	mustSucceed(err)
+	return h
+}
+
+// Memory returns the size of the table in bytes.
+func (h *HashedBitModel) Memory() int {
+	return len(h.table)
+}
+
+// Reset empties the table, forgets the learned probabilities and selects
+// context 0.
+func (h *HashedBitModel) Reset() {
+	for k := range h.table {
+		h.table[k] = 0
+	}
+	h.probs.reset()
+	h.SetContext(0)
+}
+
+// priority returns the number of bits seen by the first node of the bucket at
+// offset b.
+func (h *HashedBitModel) priority(b uint32) uint32 {
+	n := bitHistory.counts[h.table[b+1]]
+	return uint32(n[0]) + uint32(n[1])
+}
+
+// SetContext selects the bucket of a hashed context. The next four bits are
+// coded in its tree, after which the tree is used again from the top. The
+// low bits of the hash choose the bucket, so they should be well mixed.
+func (h *HashedBitModel) SetContext(hash uint32) {
+	check := uint8((hash * 0x9E3779B1) >> 24)
+	i := hash & h.mask
+	h.node = 1
+	for _, j := range [3]uint32{i, i ^ 1, i ^ 2} {
+		if h.table[j*HM__BucketSize] == check {
+			h.bucket = j * HM__BucketSize
+			return
+		}
+	}
+	victim := i * HM__BucketSize
+	for _, j := range [2]uint32{i ^ 1, i ^ 2} {
+		if h.priority(j*HM__BucketSize) < h.priority(victim) {
+			victim = j * HM__BucketSize
+		}
+	}
+	bucket := h.table[victim : victim+HM__BucketSize]
+	for k := range bucket {
+		bucket[k] = 0
+	}
+	bucket[0] = check
+	h.bucket = victim
+}
+
+// p1 returns the probability of a 1 for the next bit, over SM__MaxProb.
+func (h *HashedBitModel) p1() uint32 {
+	return h.probs.p(uint32(h.table[h.bucket+h.node]))
+}
+
+// P returns the probability of a 1 for the next bit, over MX__MaxProb.
+func (h *HashedBitModel) P() uint32 {
+	return p12(h.p1())
+}
+
+// Update records bit in its node and moves to the next one; the codec calls
+// it after each bit.
+func (h *HashedBitModel) Update(bit uint32) {
+	s := &h.table[h.bucket+h.node]
+	h.probs.update(uint32(*s), bit)
+	*s = bitHistory.next[*s][bit]
+	if h.node = 2*h.node + bit; h.node >= HM__BucketSize {
+		h.node = 1
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Encode_HashedBitModel(bit uint32, M *HashedBitModel) {
+	a.encodeBit(bit, SM__MaxProb-M.p1())
+	M.Update(bit)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_HashedBitModel(M *HashedBitModel) uint32 {
+	bit := a.decodeBit(SM__MaxProb - M.p1())
+	M.Update(bit)
+	return bit
+}
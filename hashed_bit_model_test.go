@@ -0,0 +1,104 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+func mixHash(h, x uint32) uint32 {
+	h = (h ^ x) * 0x9E3779B1
+	return h ^ h>>15
+}
+
+// hashedMixer predicts bytes from order 1 to 4 contexts kept in hash tables
+// of the same budget, and order 0, mixed, selecting each order's bucket at
+// every nibble.
+type hashedMixer struct {
+	order0 *HistoryBitModel
+	orders [4]*HashedBitModel
+	mixer  *Mixer
+	hashes [4]uint32
+	last   uint32 // the last four bytes
+}
+
+func newHashedMixer(memory uint32) *hashedMixer {
+	hm := &hashedMixer{order0: initHistoryBitModel(256)}
+	inputs := []BitPredictor{hm.order0}
+	for k := range hm.orders {
+		hm.orders[k] = initHashedBitModel(memory)
+		inputs = append(inputs, hm.orders[k])
+	}
+	hm.mixer = initMixer(inputs, 256)
+	return hm
+}
+
+func (hm *hashedMixer) setContexts(c0 uint32) {
+	if c0 == 1 || c0 >= 16 && c0 < 32 {
+		for k, order := range hm.orders {
+			order.SetContext(mixHash(hm.hashes[k], c0))
+		}
+	}
+	hm.order0.SetContext(c0)
+	hm.mixer.SetContext(c0)
+}
+
+func (hm *hashedMixer) endByte(c uint32) {
+	hm.last = hm.last<<8 | c
+	for k := range hm.hashes {
+		hm.hashes[k] = mixHash(uint32(k+1)<<24, hm.last&(0xFFFFFFFF>>uint(24-8*k)))
+	}
+}
+
+func (hm *hashedMixer) predictor() BitPredictor {
+	return hm.mixer
+}
+
+func TestHashedBitModel(t *testing.T) {
+	text := generatedText(200000, 24)
+	codec := initArithmeticCodec(uint32(len(text)), nil)
+
+	sizes := make(map[uint32]uint32)
+	for _, memory := range []uint32{1 << 12, 1 << 16, 1 << 22} {
+		codec.StartEncoder()
+		encodeBytes(codec, newHashedMixer(memory), text)
+		sizes[memory] = codec.StopEncoder()
+
+		codec.StartDecoder()
+		decoded := decodeBytes(codec, newHashedMixer(memory), len(text))
+		codec.StopDecoder()
+		if string(decoded) != string(text) {
+			t.Fatalf("%d bytes per order: incorrect decoding", memory)
+		}
+	}
+	t.Logf("%d bytes of text coded in %d, %d and %d bytes with 4 KB, 64 KB and 4 MB per order",
+		len(text), sizes[1<<12], sizes[1<<16], sizes[1<<22])
+	if sizes[1<<16] >= sizes[1<<12] || sizes[1<<22] >= sizes[1<<16] {
+		t.Errorf("a larger table did not code the text in fewer bytes: %v", sizes)
+	}
+
+	// A context seen often keeps its bucket while hundreds of new contexts
+	// compete for the three it may use.
+	model := initHashedBitModel(HM__MinMemory)
+	for k := 0; k < 40; k++ {
+		model.SetContext(7)
+		model.Update(0)
+	}
+	for k := uint32(0); k < 1000; k++ {
+		model.SetContext(mixHash(k, 1))
+		model.Update(1)
+	}
+	model.SetContext(7)
+	if p := model.P(); p > 200 {
+		t.Errorf("P() in a context seen with 40 zeros = %d after a flood of new contexts", p)
+	}
+	if got := model.Memory(); got != HM__MinMemory {
+		t.Errorf("Memory() = %d, want %d", got, HM__MinMemory)
+	}
+	if got := initHashedBitModel(3 << 20).Memory(); got != 2<<20 {
+		t.Errorf("Memory() for a budget of 3 MB = %d, want 2 MB", got)
+	}
+
+	if _, err := NewHashedBitModel(HM__MinMemory - 1); !errors.Is(err, ErrInvalidMemory) {
+		t.Errorf("NewHashedBitModel(%d) error = %v, want ErrInvalidMemory", HM__MinMemory-1, err)
+	}
+}
@@ -0,0 +1,206 @@
+package FastAC
+
+import "fmt"
+
+const (
+	LM__MaxSymbols = 1 << 20 // Large Model maximum alphabet
+	LM__CountLimit = 1 << 16 // total count limit of alphabets up to 2^14 symbols
+	LM__MaxCount   = 1 << 22 // total count limit of the largest alphabets
+	LM__Increment  = 4       // count added for each coded symbol
+)
+
+// LargeAdaptiveDataModel is an adaptive model for alphabets too big for
+// AdaptiveDataModel, up to LM__MaxSymbols symbols. Symbol counts are kept in a
+// Fenwick tree, so coding a symbol and updating its count both take
+// O(log number_of_symbols) steps, and the model adapts after every symbol
+// instead of rebuilding its distribution.
+//
+// The coded probabilities are count/total, so the codec needs a division per
+// symbol. It splits the interval in units of length/total, and the remainder,
+// less than total, goes to the last symbol: every other symbol loses up to
+// total/AC__MinLength of its interval. Counts are halved when the total passes
+// LM__CountLimit, which keeps that loss below 2^-8, as with the other data
+// models, or 0.006 bits per symbol. Alphabets above 2^14 symbols need a
+// higher limit, 4 * number_of_symbols up to LM__MaxCount, and lose up to 2^-2
+// of the interval, 0.42 bits per symbol, in the worst case; measured losses
+// are far smaller.
+type LargeAdaptiveDataModel struct {
+	tree         []uint32 // tree[k] sums symbol_count over (k - lowbit(k), k]
+	symbol_count []uint32
+
+	total_count, data_symbols, top_bit, count_limit uint32
+}
+
+// NewLargeAdaptiveDataModel returns a model for number_of_symbols symbols (2
+// to LM__MaxSymbols) that starts with equal probabilities.
+func NewLargeAdaptiveDataModel(number_of_symbols uint32) (*LargeAdaptiveDataModel, error) {
+	model := new(LargeAdaptiveDataModel)
+	if err := model.TrySetAlphabet(number_of_symbols); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func initLargeAdaptiveDataModel(number_of_symbols uint32) *LargeAdaptiveDataModel {
+	model, err := NewLargeAdaptiveDataModel(number_of_symbols)
+	mustSucceed(err)
+	return model
+}
+
+func (a *LargeAdaptiveDataModel) SetAlphabet(number_of_symbols uint32) {
+	mustSucceed(a.TrySetAlphabet(number_of_symbols))
+}
+
+func (a *LargeAdaptiveDataModel) TrySetAlphabet(number_of_symbols uint32) error {
+	if number_of_symbols < 2 || number_of_symbols > LM__MaxSymbols {
+		return codecError("SetAlphabet", ErrInvalidAlphabet, fmt.Sprint(number_of_symbols))
+	}
+
+	if a.data_symbols != number_of_symbols {
+		a.data_symbols = number_of_symbols
+		memory := make([]uint32, 2*a.data_symbols+1)
+		a.tree = memory[:a.data_symbols+1]
+		a.symbol_count = memory[a.data_symbols+1:]
+		a.top_bit = 1
+		for a.top_bit<<1 <= a.data_symbols {
+			a.top_bit <<= 1
+		}
+		a.count_limit = LM__CountLimit
+		if a.count_limit < 4*a.data_symbols {
+			a.count_limit = 4 * a.data_symbols
+		}
+	}
+	a.Reset()
+	return nil
+}
+
+func (a *LargeAdaptiveDataModel) Reset() {
+	if a.data_symbols == 0 {
+		return
+	}
+	for k := range a.symbol_count {
+		a.symbol_count[k] = 1
+	}
+	a.rebuild()
+}
+
+// rebuild computes the tree and total_count from symbol_count in O(n).
+func (a *LargeAdaptiveDataModel) rebuild() {
+	a.total_count = 0
+	for k := uint32(1); k <= a.data_symbols; k++ {
+		a.tree[k] = a.symbol_count[k-1]
+		a.total_count += a.symbol_count[k-1]
+	}
+	for k := uint32(1); k <= a.data_symbols; k++ {
+		if p := k + k&-k; p <= a.data_symbols {
+			a.tree[p] += a.tree[k]
+		}
+	}
+}
+
+// cumulative returns the sum of the counts of the symbols before data.
+func (a *LargeAdaptiveDataModel) cumulative(data uint32) uint32 {
+	sum := uint32(0)
+	for k := data; k > 0; k -= k & -k {
+		sum += a.tree[k]
+	}
+	return sum
+}
+
+// find returns the symbol whose interval holds count, and the cumulative
+// count before it.
+func (a *LargeAdaptiveDataModel) find(count uint32) (data, cum uint32) {
+	for bit := a.top_bit; bit != 0; bit >>= 1 {
+		if k := data + bit; k <= a.data_symbols && a.tree[k] <= count {
+			data = k
+			count -= a.tree[k]
+			cum += a.tree[k]
+		}
+	}
+	return data, cum
+}
+
+// Update adds the count of a coded symbol, halving all counts when the total
+// passes the model's limit.
+func (a *LargeAdaptiveDataModel) Update(data uint32) {
+	a.symbol_count[data] += LM__Increment
+	a.total_count += LM__Increment
+	if a.total_count > a.count_limit {
+		for k := range a.symbol_count {
+			a.symbol_count[k] = (a.symbol_count[k] + 1) >> 1
+		}
+		a.rebuild()
+		return
+	}
+	for k := data + 1; k <= a.data_symbols; k += k & -k {
+		a.tree[k] += LM__Increment
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Frequency coding  - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// encodeFrequency codes the interval [cum, cum + freq) out of total, which
+// must be at most LM__MaxCount. The symbol ending at total gets the
+// rounding remainder of the interval, as the last symbol does in the other
+// data models.
+func (a *ArithmeticCodec) encodeFrequency(cum, freq, total uint32) {
+	init_base := a.base
+	r := a.length / total
+	x := r * cum
+	a.base += x
+	if cum+freq == total {
+		a.length -= x
+	} else {
+		a.length = r * freq
+	}
+
+	if init_base > a.base {
+		a.PropagateCarry()
+	}
+
+	if a.length < AC__MinLength {
+		a.RenormEncInterval()
+	}
+}
+
+// decodeTarget returns the count, out of total, selected by the code. The
+// symbol holding it must then be removed with decodeFrequency.
+func (a *ArithmeticCodec) decodeTarget(total uint32) uint32 {
+	t := a.value / (a.length / total)
+	if t >= total {
+		return total - 1
+	}
+	return t
+}
+
+func (a *ArithmeticCodec) decodeFrequency(cum, freq, total uint32) {
+	r := a.length / total
+	x := r * cum
+	a.value -= x
+	if cum+freq == total {
+		a.length -= x
+	} else {
+		a.length = r * freq
+	}
+
+	if a.length < AC__MinLength {
+		a.RenormDecInterval()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Encode_LargeAdaptiveDataModel(data uint32, M *LargeAdaptiveDataModel) {
+	a.encodeFrequency(M.cumulative(data), M.symbol_count[data], M.total_count)
+	M.Update(data)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_LargeAdaptiveDataModel(M *LargeAdaptiveDataModel) uint32 {
+	s, cum := M.find(a.decodeTarget(M.total_count))
+	a.decodeFrequency(cum, M.symbol_count[s], M.total_count)
+	M.Update(s)
+	return s
+}
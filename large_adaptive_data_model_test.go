@@ -0,0 +1,82 @@
+package FastAC
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestLargeAdaptiveDataModel_RoundTrip(t *testing.T) {
+	const n = 300000
+	rg := initRandomGenerator(2009)
+	for _, data_symbols := range []uint32{2, 2048, 1 << 16, LM__MaxSymbols} {
+		// A heavy-tailed source: small symbols are far more likely.
+		data := make([]uint32, n)
+		for k := range data {
+			data[k] = (rg.Word() >> rg.Integer(32)) % data_symbols
+		}
+
+		codec := initArithmeticCodec(4*n, nil)
+		model := initLargeAdaptiveDataModel(data_symbols)
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_LargeAdaptiveDataModel(s, model)
+		}
+		code_bytes := codec.StopEncoder()
+
+		model.Reset()
+		codec.StartDecoder()
+		for k, s := range data {
+			if d := codec.Decode_LargeAdaptiveDataModel(model); d != s {
+				t.Fatalf("%d symbols: symbol %d decoded as %d, want %d", data_symbols, k, d, s)
+			}
+		}
+		codec.StopDecoder()
+
+		if data_symbols > 1<<11 {
+			continue
+		}
+		// Where both models apply, they should compress about as well.
+		plain := initAdaptiveDataModel(data_symbols)
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_AdaptiveDataModel(s, plain)
+		}
+		plain_bytes := codec.StopEncoder()
+		if math.Abs(float64(code_bytes)-float64(plain_bytes)) > 0.01*float64(plain_bytes) {
+			t.Errorf("%d symbols: large model used %d bytes, AdaptiveDataModel %d", data_symbols, code_bytes, plain_bytes)
+		}
+	}
+
+	if _, err := NewLargeAdaptiveDataModel(LM__MaxSymbols + 1); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("NewLargeAdaptiveDataModel(LM__MaxSymbols + 1) error = %v, want ErrInvalidAlphabet", err)
+	}
+}
+
+func TestLargeAdaptiveDataModel_RoundingLoss(t *testing.T) {
+	// The last symbol never occurs, so the rounding remainder it gets is all
+	// lost; the loss is what the code takes beyond the model's ideal cost.
+	const n = 300000
+	rg := initRandomGenerator(2010)
+	for _, data_symbols := range []uint32{256, 1 << 14, 1 << 16, LM__MaxSymbols} {
+		data := make([]uint32, n)
+		for k := range data {
+			data[k] = (rg.Word() >> rg.Integer(32)) % (data_symbols - 1)
+		}
+		codec := initArithmeticCodec(4*n, nil)
+		model := initLargeAdaptiveDataModel(data_symbols)
+		cost, cost_model := NewCostCodec(), initLargeAdaptiveDataModel(data_symbols)
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_LargeAdaptiveDataModel(s, model)
+			cost.Encode_LargeAdaptiveDataModel(s, cost_model)
+		}
+		loss := (8*float64(codec.StopEncoder()) - cost.Bits()) / n
+
+		bound := -math.Log2(1 - float64(model.count_limit)/AC__MinLength)
+		t.Logf("%d symbols: count limit %d, loss %.5f bits per symbol, bound %.5f", data_symbols, model.count_limit, loss, bound)
+		if data_symbols <= 1<<14 && bound > 0.006 || loss > bound {
+			t.Errorf("%d symbols: loss %.5f bits per symbol, bound %.5f", data_symbols, loss, bound)
+		}
+	}
+}
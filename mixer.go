@@ -0,0 +1,233 @@
+package FastAC
+
+import "fmt"
+
+// BitPredictor gives the probability that the next bit is 1, as a 12-bit
+// number from 1 to 4095, and learns from the bit once it is known. Update
+// must follow each P with the bit that was coded, and predictors must be
+// called in the same order by the encoder and the decoder.
+//
+// ShiftBitModel, HistoryBitModel and Mixer are predictors, so mixers can be
+// fed by models, by other mixers, or both.
+type BitPredictor interface {
+	P() uint32
+	Update(bit uint32)
+}
+
+const (
+	MX__ProbBits = 12 // bits of probability of a BitPredictor
+	MX__MaxProb  = 1 << MX__ProbBits
+)
+
+// p12 returns a probability over SM__MaxProb as a BitPredictor probability.
+func p12(p uint32) uint32 {
+	if p >>= SM__LengthShift - MX__ProbBits; p != 0 {
+		return p
+	}
+	return 1
+}
+
+// P returns the probability of a 1, over MX__MaxProb.
+func (s *ShiftBitModel) P() uint32 {
+	return p12(SM__MaxProb - s.prob0())
+}
+
+// P returns the probability of a 1 in the selected context, over
+// MX__MaxProb.
+func (h *HistoryBitModel) P() uint32 {
+	return p12(h.p1())
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Logistic domain - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// squash(x) is 4096 / (1 + e^(-x/256)), interpolated between 33 points so
+// that the encoder and the decoder compute it alike on any machine. Inputs
+// are clamped to [-2047, 2047].
+var squashPoints = [33]int32{
+	1, 2, 3, 6, 10, 16, 27, 45, 73, 120, 194, 310, 488, 747, 1101, 1546,
+	2047, 2549, 2994, 3348, 3607, 3785, 3901, 3975, 4022, 4050, 4068, 4079,
+	4085, 4089, 4092, 4093, 4094}
+
+func squash(x int32) int32 {
+	if x > 2047 {
+		return 4095
+	}
+	if x < -2047 {
+		return 1
+	}
+	w := x & 127
+	k := (x >> 7) + 16
+	return (squashPoints[k]*(128-w) + squashPoints[k+1]*w + 64) >> 7
+}
+
+// stretchTable inverts squash: stretch(p) = ln(p / (4096 - p)) * 256.
+var stretchTable = buildStretchTable()
+
+func buildStretchTable() *[MX__MaxProb]int16 {
+	t := new([MX__MaxProb]int16)
+	p := int32(0)
+	for x := int32(-2047); x <= 2047; x++ {
+		for v := squash(x); p <= v; p++ {
+			t[p] = int16(x)
+		}
+	}
+	for ; p < MX__MaxProb; p++ {
+		t[p] = 2047
+	}
+	return t
+}
+
+func stretch(p uint32) int32 {
+	return int32(stretchTable[p])
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Mixer - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+const (
+	MX__MaxInputs      = 256
+	mixerLearningShift = 11 // weights move by stretch * error >> mixerLearningShift
+	mixerWeightLimit   = 1 << 24
+)
+
+// Mixer combines the probabilities of several predictors by logistic mixing:
+// it adds their stretched probabilities, ln(p / (1 - p)), with weights, and
+// squashes the sum back into a probability. After each bit the weights take
+// a gradient step on the coding cost, so the mixer learns which predictors to
+// trust. A context selects one of several weight sets, so that trust can
+// depend on, for instance, the bit position or the order of the longest
+// context seen before.
+//
+// With an APM enabled, the mixed probability is refined in the selected
+// context and averaged with the refinement, which usually saves a few more
+// percent.
+//
+// A Mixer is a BitPredictor: P predicts from the inputs' current
+// probabilities and Update passes the bit on to every input. Set the inputs'
+// contexts before calling P.
+type Mixer struct {
+	inputs    []BitPredictor
+	stretched []int32 // of the last P, for Update
+	weights   []int32 // weight sets of len(inputs) weights, 1.0 = 1 << 16
+	sets      uint32
+	context   uint32
+	mixed     uint32 // last probability before the APM
+	apm       *apm
+}
+
+// NewMixer returns a mixer of 1 to MX__MaxInputs predictors with
+// weight_sets weight sets, from 1 to 2^16, and weight set 0 selected. The
+// weights start equal, summing to 1.
+func NewMixer(inputs []BitPredictor, weight_sets uint32) (*Mixer, error) {
+	if len(inputs) < 1 || len(inputs) > MX__MaxInputs {
+		return nil, codecError("NewMixer", ErrInvalidInputs, fmt.Sprint(len(inputs)))
+	}
+	if weight_sets < 1 || weight_sets > 1<<16 {
+		return nil, codecError("NewMixer", ErrInvalidContext, fmt.Sprintf("%d weight sets", weight_sets))
+	}
+	m := &Mixer{
+		inputs:    append([]BitPredictor(nil), inputs...),
+		stretched: make([]int32, len(inputs)),
+		weights:   make([]int32, int(weight_sets)*len(inputs)),
+		sets:      weight_sets,
+	}
+	m.Reset()
+	return m, nil
+}
+
+func initMixer(inputs []BitPredictor, weight_sets uint32) *Mixer {
+	m, err := NewMixer(inputs, weight_sets)
+	mustSucceed(err)
+	return m
+}
+
+// EnableAPM adds an APM stage with one context per weight set, selected along
+// with the weight set.
+func (m *Mixer) EnableAPM() {
+	a := newAPM(m.sets)
+	m.apm = &a
+}
+
+// Reset returns the weights, and the APM if enabled, to their initial state.
+// The inputs are not reset.
+func (m *Mixer) Reset() {
+	w := int32(1<<16) / int32(len(m.inputs))
+	for k := range m.weights {
+		m.weights[k] = w
+	}
+	if m.apm != nil {
+		m.apm.reset()
+	}
+	m.context = 0
+}
+
+func (m *Mixer) SetContext(context uint32) {
+	mustSucceed(m.TrySetContext(context))
+}
+
+// TrySetContext selects the weight set, and APM context, used by the next P.
+func (m *Mixer) TrySetContext(context uint32) error {
+	if context >= m.sets {
+		return codecError("SetContext", ErrInvalidContext, fmt.Sprintf("weight set %d of %d", context, m.sets))
+	}
+	m.context = context
+	return nil
+}
+
+// P returns the mixed probability of a 1, over MX__MaxProb.
+func (m *Mixer) P() uint32 {
+	w := m.weights[int(m.context)*len(m.inputs):]
+	dot := int64(0)
+	for k, input := range m.inputs {
+		m.stretched[k] = stretch(input.P())
+		dot += int64(m.stretched[k]) * int64(w[k])
+	}
+	dot >>= 16
+	if dot > 2047 {
+		dot = 2047
+	} else if dot < -2047 {
+		dot = -2047
+	}
+	m.mixed = uint32(squash(int32(dot)))
+	if m.apm == nil {
+		return m.mixed
+	}
+	return m.apm.blend(m.mixed, m.context)
+}
+
+// Update trains the selected weight set on bit, which must follow a call to
+// P, and updates the inputs.
+func (m *Mixer) Update(bit uint32) {
+	err := (int32(bit) << MX__ProbBits) - int32(m.mixed)
+	w := m.weights[int(m.context)*len(m.inputs):]
+	for k, input := range m.inputs {
+		v := w[k] + (m.stretched[k]*err)>>mixerLearningShift
+		if v > mixerWeightLimit {
+			v = mixerWeightLimit
+		} else if v < -mixerWeightLimit {
+			v = -mixerWeightLimit
+		}
+		w[k] = v
+		input.Update(bit)
+	}
+	if m.apm != nil {
+		m.apm.update(bit)
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Predictor coding  - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Encode_BitPredictor(bit uint32, P BitPredictor) {
+	a.encodeBit(bit, (MX__MaxProb-P.P())<<(SM__LengthShift-MX__ProbBits))
+	P.Update(bit)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_BitPredictor(P BitPredictor) uint32 {
+	bit := a.decodeBit((MX__MaxProb - P.P()) << (SM__LengthShift - MX__ProbBits))
+	P.Update(bit)
+	return bit
+}
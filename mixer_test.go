@@ -0,0 +1,181 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+// generatedText returns n bytes of text made of words drawn from a small
+// vocabulary, the frequent words far more likely, with punctuation and line
+// breaks, so that contexts of several orders help predict it.
+func generatedText(n int, seed uint32) []byte {
+	words := []string{
+		"the", "of", "and", "to", "in", "a", "is", "that", "for", "it",
+		"as", "was", "with", "be", "by", "on", "not", "he", "this", "are",
+		"arithmetic", "coding", "model", "probability", "interval", "symbol",
+		"context", "adaptive", "encoder", "decoder", "compression", "entropy",
+		"source", "distribution", "estimate", "statistics", "alphabet",
+		"frequency", "table", "range", "carry", "precision", "renormalize",
+	}
+	rg := initRandomGenerator(seed)
+	text := make([]byte, 0, n+16)
+	line := 0
+	for len(text) < n {
+		// Squaring a uniform index favors the first words.
+		k := rg.Integer(uint32(len(words)))
+		k = k * rg.Integer(uint32(len(words))) / uint32(len(words))
+		text = append(text, words[k]...)
+		line += len(words[k]) + 1
+		switch r := rg.Integer(20); {
+		case r == 0:
+			text = append(text, ". "...)
+		case r == 1:
+			text = append(text, ", "...)
+		case line > 70:
+			text = append(text, '\n')
+			line = 0
+		default:
+			text = append(text, ' ')
+		}
+	}
+	return text[:n]
+}
+
+// contextMixer predicts the bits of bytes from order 0 to 3 contexts.
+type contextMixer struct {
+	orders []*HistoryBitModel
+	mixer  *Mixer
+	hashes [4]uint32 // of the bytes of each order, before the current byte
+}
+
+const contextMixerBits = 18
+
+func newContextMixer(orders int, apm bool) *contextMixer {
+	cm := new(contextMixer)
+	inputs := make([]BitPredictor, orders)
+	for k := range inputs {
+		cm.orders = append(cm.orders, initHistoryBitModel(1<<contextMixerBits))
+		inputs[k] = cm.orders[k]
+	}
+	cm.mixer = initMixer(inputs, 256)
+	if apm {
+		cm.mixer.EnableAPM()
+	}
+	return cm
+}
+
+func (cm *contextMixer) setContexts(c0 uint32) {
+	for k, order := range cm.orders {
+		order.SetContext((cm.hashes[k] + c0*0x9E3779B1) >> (32 - contextMixerBits))
+	}
+	cm.mixer.SetContext(c0)
+}
+
+func (cm *contextMixer) endByte(c uint32) {
+	for k := len(cm.hashes) - 1; k > 0; k-- {
+		cm.hashes[k] = (cm.hashes[k-1] + c + 1) * (0x2F0F3B5 << uint(k))
+	}
+}
+
+func (cm *contextMixer) predictor() BitPredictor {
+	return cm.mixer
+}
+
+// byteModel is a test model that codes bytes a bit at a time, most
+// significant first, from a predictor whose contexts it selects.
+type byteModel interface {
+	// setContexts selects the contexts of the next bit, given the bits c0 of
+	// the current byte after a leading 1.
+	setContexts(c0 uint32)
+	// endByte updates the contexts with the byte just coded.
+	endByte(c uint32)
+	predictor() BitPredictor
+}
+
+func encodeBytes(codec *ArithmeticCodec, m byteModel, text []byte) {
+	p := m.predictor()
+	for _, c := range text {
+		c0 := uint32(1)
+		for k := 7; k >= 0; k-- {
+			bit := uint32(c>>uint(k)) & 1
+			m.setContexts(c0)
+			codec.Encode_BitPredictor(bit, p)
+			c0 = c0<<1 | bit
+		}
+		m.endByte(uint32(c))
+	}
+}
+
+func decodeBytes(codec *ArithmeticCodec, m byteModel, n int) []byte {
+	p := m.predictor()
+	text := make([]byte, n)
+	for i := range text {
+		c0 := uint32(1)
+		for c0 < 256 {
+			m.setContexts(c0)
+			c0 = c0<<1 | codec.Decode_BitPredictor(p)
+		}
+		text[i] = byte(c0)
+		m.endByte(uint32(text[i]))
+	}
+	return text
+}
+
+func contextMixerBytes(text []byte, orders int, apm bool) uint32 {
+	codec := initArithmeticCodec(uint32(len(text)), nil)
+	codec.StartEncoder()
+	encodeBytes(codec, newContextMixer(orders, apm), text)
+	return codec.StopEncoder()
+}
+
+func TestMixer(t *testing.T) {
+	text := generatedText(100000, 21)
+
+	codec := initArithmeticCodec(uint32(len(text)), nil)
+	codec.StartEncoder()
+	encodeBytes(codec, newContextMixer(4, true), text)
+	bytes := codec.StopEncoder()
+
+	codec.StartDecoder()
+	decoded := decodeBytes(codec, newContextMixer(4, true), len(text))
+	codec.StopDecoder()
+	if string(decoded) != string(text) {
+		t.Fatal("incorrect decoding")
+	}
+
+	// Each stage pays for itself: more orders, then the APM.
+	order0, order2, order4 := contextMixerBytes(text, 1, false), contextMixerBytes(text, 2, false), contextMixerBytes(text, 4, false)
+	t.Logf("%d bytes of text: order 0 %d, orders 0-1 %d, orders 0-3 %d, with APM %d bytes", len(text), order0, order2, order4, bytes)
+	if order2 >= order0 || order4 >= order2 || bytes >= order4 {
+		t.Errorf("coded %d bytes of text in %d, %d, %d and %d bytes", len(text), order0, order2, order4, bytes)
+	}
+
+	// Mixing two predictors beats either alone on bits that follow the fast
+	// one half of the time and the slow one the other half.
+	fast, slow := initShiftBitModel(4), initShiftBitModel(8)
+	mixer := initMixer([]BitPredictor{fast, slow}, 1)
+	data := append(nonstationaryBits(50000, 500), nonstationaryBits(50000, 0)...)
+	mixed := NewCostCodec()
+	for _, bit := range data {
+		mixed.Encode_BitPredictor(bit, mixer)
+	}
+	for _, rate := range []uint32{4, 8} {
+		if single := costBits(initShiftBitModel(rate), data, nil); mixed.Bits() >= single {
+			t.Errorf("mixer used %.0f bits, ShiftBitModel(%d) %.0f", mixed.Bits(), rate, single)
+		}
+	}
+
+	// stretch inverts squash.
+	for x := int32(-2047); x <= 2047; x++ {
+		if y := stretch(uint32(squash(x))); squash(y) != squash(x) {
+			t.Fatalf("squash(stretch(squash(%d))) = %d, want %d", x, squash(y), squash(x))
+		}
+	}
+
+	if _, err := NewMixer(nil, 1); !errors.Is(err, ErrInvalidInputs) {
+		t.Errorf("NewMixer(nil) error = %v, want ErrInvalidInputs", err)
+	}
+	if err := mixer.TrySetContext(1); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("TrySetContext(1) error = %v, want ErrInvalidContext", err)
+	}
+}
@@ -1,6 +1,177 @@
 package FastAC
 
+// Model is a model attached to a codec. Encode and Decode code one symbol
+// with the model's current statistics, so coders written against Model can
+// swap models at runtime. Bind a model to a codec with its Bind method.
 type Model interface {
-	Encode(value uint)
-	Decode() uint
+	Encode(symbol uint32)
+	Decode() uint32
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundStaticBitModel struct {
+	codec *ArithmeticCodec
+	model *StaticBitModel
+}
+
+func (b boundStaticBitModel) Encode(symbol uint32) { b.codec.Encode_StaticBitModel(symbol, b.model) }
+func (b boundStaticBitModel) Decode() uint32       { return b.codec.Decode_StaticBitModel(b.model) }
+
+// Bind returns the model attached to codec.
+func (s *StaticBitModel) Bind(codec *ArithmeticCodec) Model {
+	return boundStaticBitModel{codec, s}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundAdaptiveBitModel struct {
+	codec *ArithmeticCodec
+	model *AdaptiveBitModel
+}
+
+func (b boundAdaptiveBitModel) Encode(symbol uint32) {
+	b.codec.Encode_AdaptiveBitModel(symbol, b.model)
+}
+func (b boundAdaptiveBitModel) Decode() uint32 { return b.codec.Decode_AdaptiveBitModel(b.model) }
+
+// Bind returns the model attached to codec.
+func (a *AdaptiveBitModel) Bind(codec *ArithmeticCodec) Model {
+	return boundAdaptiveBitModel{codec, a}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundShiftBitModel struct {
+	codec *ArithmeticCodec
+	model *ShiftBitModel
+}
+
+func (b boundShiftBitModel) Encode(symbol uint32) { b.codec.Encode_ShiftBitModel(symbol, b.model) }
+func (b boundShiftBitModel) Decode() uint32       { return b.codec.Decode_ShiftBitModel(b.model) }
+
+// Bind returns the model attached to codec.
+func (s *ShiftBitModel) Bind(codec *ArithmeticCodec) Model {
+	return boundShiftBitModel{codec, s}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundHistoryBitModel struct {
+	codec *ArithmeticCodec
+	model *HistoryBitModel
+}
+
+func (b boundHistoryBitModel) Encode(symbol uint32) {
+	b.codec.Encode_HistoryBitModel(symbol, b.model)
+}
+func (b boundHistoryBitModel) Decode() uint32 { return b.codec.Decode_HistoryBitModel(b.model) }
+
+// Bind returns the model attached to codec. The bound model codes in the
+// context selected with SetContext.
+func (h *HistoryBitModel) Bind(codec *ArithmeticCodec) Model {
+	return boundHistoryBitModel{codec, h}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundHashedBitModel struct {
+	codec *ArithmeticCodec
+	model *HashedBitModel
+}
+
+func (b boundHashedBitModel) Encode(symbol uint32) {
+	b.codec.Encode_HashedBitModel(symbol, b.model)
+}
+func (b boundHashedBitModel) Decode() uint32 { return b.codec.Decode_HashedBitModel(b.model) }
+
+// Bind returns the model attached to codec. The bound model codes in the
+// context selected with SetContext.
+func (h *HashedBitModel) Bind(codec *ArithmeticCodec) Model {
+	return boundHashedBitModel{codec, h}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundStaticDataModel struct {
+	codec *ArithmeticCodec
+	model *StaticDataModel
+}
+
+func (b boundStaticDataModel) Encode(symbol uint32) { b.codec.Encode_StaticDataModel(symbol, b.model) }
+func (b boundStaticDataModel) Decode() uint32       { return b.codec.Decode_StaticDataModel(b.model) }
+
+// Bind returns the model attached to codec.
+func (sdm *StaticDataModel) Bind(codec *ArithmeticCodec) Model {
+	return boundStaticDataModel{codec, sdm}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundAdaptiveDataModel struct {
+	codec *ArithmeticCodec
+	model *AdaptiveDataModel
+}
+
+func (b boundAdaptiveDataModel) Encode(symbol uint32) {
+	b.codec.Encode_AdaptiveDataModel(symbol, b.model)
+}
+func (b boundAdaptiveDataModel) Decode() uint32 { return b.codec.Decode_AdaptiveDataModel(b.model) }
+
+// Bind returns the model attached to codec.
+func (a *AdaptiveDataModel) Bind(codec *ArithmeticCodec) Model {
+	return boundAdaptiveDataModel{codec, a}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundSortedAdaptiveDataModel struct {
+	codec *ArithmeticCodec
+	model *SortedAdaptiveDataModel
+}
+
+func (b boundSortedAdaptiveDataModel) Encode(symbol uint32) {
+	b.codec.Encode_SortedAdaptiveDataModel(symbol, b.model)
+}
+func (b boundSortedAdaptiveDataModel) Decode() uint32 {
+	return b.codec.Decode_SortedAdaptiveDataModel(b.model)
+}
+
+// Bind returns the model attached to codec.
+func (a *SortedAdaptiveDataModel) Bind(codec *ArithmeticCodec) Model {
+	return boundSortedAdaptiveDataModel{codec, a}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundLargeAdaptiveDataModel struct {
+	codec *ArithmeticCodec
+	model *LargeAdaptiveDataModel
+}
+
+func (b boundLargeAdaptiveDataModel) Encode(symbol uint32) {
+	b.codec.Encode_LargeAdaptiveDataModel(symbol, b.model)
+}
+func (b boundLargeAdaptiveDataModel) Decode() uint32 {
+	return b.codec.Decode_LargeAdaptiveDataModel(b.model)
+}
+
+// Bind returns the model attached to codec.
+func (a *LargeAdaptiveDataModel) Bind(codec *ArithmeticCodec) Model {
+	return boundLargeAdaptiveDataModel{codec, a}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type boundPPMModel struct {
+	codec *ArithmeticCodec
+	model *PPMModel
+}
+
+func (b boundPPMModel) Encode(symbol uint32) { b.codec.Encode_PPMModel(symbol, b.model) }
+func (b boundPPMModel) Decode() uint32       { return b.codec.Decode_PPMModel(b.model) }
+
+// Bind returns the model attached to codec.
+func (p *PPMModel) Bind(codec *ArithmeticCodec) Model {
+	return boundPPMModel{codec, p}
 }
@@ -0,0 +1,216 @@
+package FastAC
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Adaptive models marshal to a format byte, tagged with the model type,
+// followed by their state as little-endian uint32 values:
+//
+//	AdaptiveBitModel:  update_cycle bits_until_update bit_0_prob bit_0_count bit_count
+//	                   adaptation
+//	AdaptiveDataModel: data_symbols total_count update_cycle symbols_until_update
+//	                   distribution[data_symbols] symbol_count[data_symbols]
+//	                   adaptation
+//
+// The adaptation is the four Adaptation fields: as resolved for bit models,
+// as requested for data models, whose defaults depend on the alphabet. The
+// distribution is kept because it only follows the counts at each update;
+// the decoder table is rebuilt from it.
+const (
+	adaptiveBitModelFormat  = 0x11
+	adaptiveDataModelFormat = 0x21
+)
+
+func appendAdaptation(b []byte, ad Adaptation) []byte {
+	return appendUint32s(b, ad.InitialCycle, ad.Growth, ad.MaxCycle, ad.CountLimit)
+}
+
+func readAdaptation(data []byte) Adaptation {
+	word := func(k int) uint32 { return binary.LittleEndian.Uint32(data[4*k:]) }
+	return Adaptation{word(0), word(1), word(2), word(3)}
+}
+
+func appendUint32s(b []byte, v ...uint32) []byte {
+	for _, x := range v {
+		b = append(b, byte(x), byte(x>>8), byte(x>>16), byte(x>>24))
+	}
+	return b
+}
+
+func errModelState(detail string) error {
+	return codecError("UnmarshalBinary", ErrCorruptInput, detail)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// Clone returns an independent copy of the model, including what it has
+// learned so far.
+func (a *AdaptiveBitModel) Clone() *AdaptiveBitModel {
+	c := *a
+	return &c
+}
+
+func (a *AdaptiveBitModel) MarshalBinary() ([]byte, error) {
+	b := []byte{adaptiveBitModelFormat}
+	b = appendUint32s(b, a.update_cycle, a.bits_until_update, a.bit_0_prob, a.bit_0_count, a.bit_count)
+	return appendAdaptation(b, a.settings()), nil
+}
+
+// UnmarshalBinary restores a model saved by MarshalBinary. It fails, leaving
+// the model unchanged, on data that no sequence of updates can produce.
+func (a *AdaptiveBitModel) UnmarshalBinary(data []byte) error {
+	if len(data) != 1+9*4 || data[0] != adaptiveBitModelFormat {
+		return errModelState("not an AdaptiveBitModel")
+	}
+	ad := readAdaptation(data[1+5*4:])
+	var v [5]uint32
+	for k := range v {
+		v[k] = binary.LittleEndian.Uint32(data[1+4*k:])
+	}
+	m := AdaptiveBitModel{update_cycle: v[0], bits_until_update: v[1], bit_0_prob: v[2], bit_0_count: v[3], bit_count: v[4]}
+	var err error
+	if m.adaptation, err = ad.resolve("UnmarshalBinary", 4, 64, 16, BM__MaxCount); err != nil {
+		return errModelState("invalid adaptation")
+	}
+	// At each update bit_0_count is below bit_count; since then it may have
+	// grown by one for each bit coded. Halving a bit_count that passed the
+	// limit by a whole cycle can leave it above the limit, up to
+	// max_cycle + 3 after repeated halvings.
+	coded := m.update_cycle - m.bits_until_update
+	max_count := uint32(m.count_limit)
+	if settled := uint32(m.max_cycle) + 3; settled > max_count {
+		max_count = settled
+	}
+	if m.bits_until_update == 0 || m.bits_until_update > m.update_cycle || m.update_cycle > uint32(m.max_cycle) ||
+		m.bit_0_prob == 0 || m.bit_0_prob >= BM__MaxCount ||
+		m.bit_0_count == 0 || m.bit_0_count >= m.bit_count+coded || m.bit_count > max_count {
+		return errModelState("inconsistent AdaptiveBitModel")
+	}
+	*a = m
+	return nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// Clone returns an independent copy of the model, including what it has
+// learned so far.
+func (a *AdaptiveDataModel) Clone() *AdaptiveDataModel {
+	c := *a
+	c.distribution = append([]uint32(nil), a.distribution...)
+	c.symbol_count = c.distribution[c.data_symbols : 2*c.data_symbols]
+	if c.decoder_table != nil {
+		c.decoder_table = c.distribution[2*c.data_symbols:]
+	}
+	return &c
+}
+
+func (a *AdaptiveDataModel) MarshalBinary() ([]byte, error) {
+	if a.data_symbols == 0 {
+		return nil, codecError("MarshalBinary", ErrInvalidAlphabet, "model has no alphabet")
+	}
+	b := make([]byte, 1, 1+4*(8+2*a.data_symbols))
+	b[0] = adaptiveDataModelFormat
+	b = appendUint32s(b, a.data_symbols, a.total_count, a.update_cycle, a.symbols_until_update)
+	b = appendUint32s(b, a.distribution[:a.data_symbols]...)
+	b = appendUint32s(b, a.symbol_count[:a.data_symbols]...)
+	return appendAdaptation(b, a.requested), nil
+}
+
+// UnmarshalBinary restores a model saved by MarshalBinary, with the alphabet
+// it had. It fails, leaving the model unchanged, on data that no sequence of
+// updates can produce.
+func (a *AdaptiveDataModel) UnmarshalBinary(data []byte) error {
+	if len(data) < 1+4*4 || data[0] != adaptiveDataModelFormat {
+		return errModelState("not an AdaptiveDataModel")
+	}
+	word := func(k int) uint32 { return binary.LittleEndian.Uint32(data[1+4*k:]) }
+	data_symbols := word(0)
+	size := 1 + 4*(8+2*int(data_symbols))
+	if data_symbols < 2 || data_symbols > (1<<11) || len(data) != size {
+		return errModelState(fmt.Sprintf("bad size for %d symbols", data_symbols))
+	}
+
+	m := AdaptiveDataModel{total_count: word(1), update_cycle: word(2), symbols_until_update: word(3)}
+	if m.symbols_until_update == 0 || m.symbols_until_update > m.update_cycle {
+		return errModelState("inconsistent update cycle")
+	}
+	m.requested = readAdaptation(data[size-4*4:])
+	if err := m.TrySetAlphabet(data_symbols); err != nil {
+		return errModelState("invalid adaptation")
+	}
+	m.total_count, m.update_cycle, m.symbols_until_update = word(1), word(2), word(3)
+	// Halving a total that passed the limit by a whole cycle rounds each
+	// count up, and can leave the total above the limit, up to
+	// max_cycle + data_symbols after repeated halvings.
+	max_count := uint32(m.count_limit)
+	if settled := uint32(m.max_cycle) + data_symbols; settled > max_count {
+		max_count = settled
+	}
+	if m.update_cycle > uint32(m.max_cycle) || m.total_count == 0 || m.total_count > max_count {
+		return errModelState("inconsistent update cycle")
+	}
+	sum := uint64(0)
+	for k := uint32(0); k < data_symbols; k++ {
+		m.distribution[k] = word(4 + int(k))
+		m.symbol_count[k] = word(4 + int(data_symbols+k))
+		if k > 0 && m.distribution[k] <= m.distribution[k-1] || m.distribution[k] >= DM__MaxCount {
+			return errModelState("distribution is not increasing")
+		}
+		if m.symbol_count[k] == 0 || m.symbol_count[k] > max_count+m.update_cycle {
+			return errModelState("symbol count out of range")
+		}
+		sum += uint64(m.symbol_count[k])
+	}
+	// At each update total_count is the sum of the counts; since then one
+	// count has grown for each symbol coded.
+	if sum != uint64(m.total_count)+uint64(m.update_cycle-m.symbols_until_update) {
+		return errModelState("symbol counts do not add up to total_count")
+	}
+	if m.distribution[0] != 0 {
+		return errModelState("distribution does not start at 0")
+	}
+	m.buildDecoderTable()
+	*a = m
+	return nil
+}
+
+// buildDecoderTable fills decoder_table from the distribution, as Update does
+// for the decoder.
+func (a *AdaptiveDataModel) buildDecoderTable() {
+	if a.table_size == 0 {
+		return
+	}
+	s := uint32(0)
+	for k := uint32(0); k < a.data_symbols; k++ {
+		w := a.distribution[k] >> a.table_shift
+		for s < w {
+			s++
+			a.decoder_table[s] = k - 1
+		}
+	}
+	a.decoder_table[0] = 0
+	for s <= a.table_size {
+		s++
+		a.decoder_table[s] = a.data_symbols - 1
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// Freeze returns a StaticBitModel with the model's current probabilities.
+func (a *AdaptiveBitModel) Freeze() *StaticBitModel {
+	return &StaticBitModel{bit_0_prob: a.bit_0_prob}
+}
+
+// Freeze returns a StaticDataModel with the model's current distribution,
+// which codes exactly like the adaptive model would until its next update.
+// Both models size their decoder tables the same way.
+func (a *AdaptiveDataModel) Freeze() *StaticDataModel {
+	sdm := NewStaticDataModel()
+	sdm.setAlphabet(a.data_symbols, DM__LengthShift)
+	copy(sdm.distribution, a.distribution[:a.data_symbols])
+	sdm.buildDecoderTable()
+	return sdm
+}
@@ -0,0 +1,206 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAdaptiveModel_MarshalBinary(t *testing.T) {
+	rg := initRandomGenerator(13)
+	bits := make([]uint32, 30011)
+	data := make([]uint32, 30011)
+	for k := range data {
+		bits[k] = uint32(rg.Integer(10) / 9)
+		data[k] = rg.Integer(30) * rg.Integer(30)
+	}
+
+	// Warm up the models on the first half, then code the second half with
+	// the originals, restored copies and clones: all must agree.
+	bit_model, data_model := initAdaptiveBitModel(), initAdaptiveDataModel(900)
+	codec := initArithmeticCodec(1<<16, nil)
+	codec.StartEncoder()
+	for k := 0; k < len(data)/2; k++ {
+		codec.Encode_AdaptiveBitModel(bits[k], bit_model)
+		codec.Encode_AdaptiveDataModel(data[k], data_model)
+	}
+	codec.StopEncoder()
+
+	bit_state, err := bit_model.MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdaptiveBitModel.MarshalBinary() error = %v", err)
+	}
+	data_state, err := data_model.MarshalBinary()
+	if err != nil {
+		t.Fatalf("AdaptiveDataModel.MarshalBinary() error = %v", err)
+	}
+	restored_bit, restored_data := initAdaptiveBitModel(), initAdaptiveDataModel(2)
+	if err := restored_bit.UnmarshalBinary(bit_state); err != nil {
+		t.Fatalf("AdaptiveBitModel.UnmarshalBinary() error = %v", err)
+	}
+	if err := restored_data.UnmarshalBinary(data_state); err != nil {
+		t.Fatalf("AdaptiveDataModel.UnmarshalBinary() error = %v", err)
+	}
+	cloned_bit, cloned_data := bit_model.Clone(), data_model.Clone()
+
+	encode := func(bit_model *AdaptiveBitModel, data_model *AdaptiveDataModel) []byte {
+		codec.StartEncoder()
+		for k := len(data) / 2; k < len(data); k++ {
+			codec.Encode_AdaptiveBitModel(bits[k], bit_model)
+			codec.Encode_AdaptiveDataModel(data[k], data_model)
+		}
+		return append([]byte(nil), codec.code_buffer[:codec.StopEncoder()]...)
+	}
+	want := encode(bit_model, data_model)
+	for name, got := range map[string][]byte{
+		"restored": encode(restored_bit, restored_data),
+		"cloned":   encode(cloned_bit, cloned_data),
+	} {
+		if string(got) != string(want) {
+			t.Errorf("%s models coded %d bytes differently from the originals", name, len(got))
+		}
+	}
+
+	// The decoder gets its table from the restored distribution.
+	restored_data.UnmarshalBinary(data_state)
+	restored_bit.UnmarshalBinary(bit_state)
+	copy(codec.code_buffer, want)
+	codec.StartDecoder()
+	for k := len(data) / 2; k < len(data); k++ {
+		if b := codec.Decode_AdaptiveBitModel(restored_bit); b != bits[k] {
+			t.Fatalf("bit %d decoded as %d, want %d", k, b, bits[k])
+		}
+		if s := codec.Decode_AdaptiveDataModel(restored_data); s != data[k] {
+			t.Fatalf("symbol %d decoded as %d, want %d", k, s, data[k])
+		}
+	}
+	codec.StopDecoder()
+
+	data_state[len(data_state)-1] = 0xFF
+	if err := restored_data.UnmarshalBinary(data_state); !errors.Is(err, ErrCorruptInput) {
+		t.Errorf("UnmarshalBinary(bad count) error = %v, want ErrCorruptInput", err)
+	}
+	if err := restored_bit.UnmarshalBinary(data_state); !errors.Is(err, ErrCorruptInput) {
+		t.Errorf("AdaptiveBitModel.UnmarshalBinary(data model) error = %v, want ErrCorruptInput", err)
+	}
+}
+
+func TestAdaptiveModel_Freeze(t *testing.T) {
+	rg := initRandomGenerator(17)
+	bit_model, data_model := initAdaptiveBitModel(), initAdaptiveDataModel(500)
+	codec := initArithmeticCodec(1<<18, nil)
+	codec.StartEncoder()
+	for k := 0; k < 50000; k++ {
+		codec.Encode_AdaptiveBitModel(rg.Integer(4)/3, bit_model)
+		codec.Encode_AdaptiveDataModel(rg.Integer(25)*rg.Integer(20), data_model)
+	}
+	codec.StopEncoder()
+	static_bit, static_data := bit_model.Freeze(), data_model.Freeze()
+
+	for s := uint32(0); s < 500; s++ {
+		if static_data.Probability(s) != data_model.Probability(s) {
+			t.Fatalf("frozen Probability(%d) = %v, adaptive %v", s, static_data.Probability(s), data_model.Probability(s))
+		}
+	}
+	if static_bit.Probability(0) != bit_model.Probability(0) {
+		t.Errorf("frozen bit Probability(0) = %v, adaptive %v", static_bit.Probability(0), bit_model.Probability(0))
+	}
+	table := data_model.Clone()
+	table.buildDecoderTable()
+	if string(appendUint32s(nil, static_data.decoder_table...)) != string(appendUint32s(nil, table.decoder_table...)) {
+		t.Errorf("frozen decoder_table differs from the adaptive model's")
+	}
+
+	data := make([]uint32, 20000)
+	codec.StartEncoder()
+	for k := range data {
+		data[k] = rg.Integer(25) * rg.Integer(20)
+		codec.Encode_StaticBitModel(data[k]&1, static_bit)
+		codec.Encode_StaticDataModel(data[k], static_data)
+	}
+	codec.StopEncoder()
+	codec.StartDecoder()
+	for k, s := range data {
+		if b := codec.Decode_StaticBitModel(static_bit); b != s&1 {
+			t.Fatalf("bit %d decoded as %d, want %d", k, b, s&1)
+		}
+		if d := codec.Decode_StaticDataModel(static_data); d != s {
+			t.Fatalf("symbol %d decoded as %d, want %d", k, d, s)
+		}
+	}
+	codec.StopDecoder()
+}
+
+func TestAdaptiveModel_UnmarshalInconsistent(t *testing.T) {
+	// Every state reached while coding is accepted, including those between
+	// updates, with the default and the extreme adaptations.
+	rg := initRandomGenerator(19)
+	cost := NewCostCodec()
+	// The last one lets the total pass its limit by a whole cycle, so that
+	// the halved total stays above the limit.
+	for _, ad := range []Adaptation{DefaultAdaptation, FastAdaptation, StationaryAdaptation, {Growth: 64, MaxCycle: 40, CountLimit: 40}} {
+		bit_model, err := NewAdaptiveBitModelWith(ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data_model, err := NewAdaptiveDataModelWith(20, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var restored_bit AdaptiveBitModel
+		var restored_data AdaptiveDataModel
+		for k := 0; k < 20000; k++ {
+			// Long runs of zeros and of one symbol push the counts to their
+			// limits.
+			bit, symbol := uint32(0), uint32(0)
+			if k%5000 > 3000 {
+				bit, symbol = rg.Integer(2), rg.Integer(20)
+			}
+			cost.Encode_AdaptiveBitModel(bit, bit_model)
+			cost.Encode_AdaptiveDataModel(symbol, data_model)
+			bit_state, _ := bit_model.MarshalBinary()
+			data_state, _ := data_model.MarshalBinary()
+			if err := restored_bit.UnmarshalBinary(bit_state); err != nil {
+				t.Fatalf("%+v, bit %d: UnmarshalBinary() error = %v", ad, k, err)
+			}
+			if err := restored_data.UnmarshalBinary(data_state); err != nil {
+				t.Fatalf("%+v, symbol %d: UnmarshalBinary() error = %v", ad, k, err)
+			}
+		}
+	}
+
+	// States no sequence of updates produces are rejected, so that the next
+	// update can neither divide by zero nor give a bit a probability above 1.
+	setWord := func(state []byte, k int, v uint32) []byte {
+		state = append([]byte(nil), state...)
+		copy(state[1+4*k:], appendUint32s(nil, v))
+		return state
+	}
+	data_state, _ := initAdaptiveDataModel(20).MarshalBinary()
+	for name, state := range map[string][]byte{
+		"total_count overflowing": setWord(setWord(setWord(data_state, 1, 0xFFFFFFFF), 2, 1), 3, 1),
+		"total_count zero":        setWord(data_state, 1, 0),
+		"total_count off by one":  setWord(data_state, 1, 21),
+		"symbol count off by one": setWord(data_state, 4+20, 2),
+		"update_cycle too long":   setWord(setWord(data_state, 2, 1<<20), 3, 1<<20),
+		"repeated distribution":   setWord(data_state, 4+1, 0),
+		"flat distribution":       setWord(setWord(setWord(data_state, 4+1, 0), 4+2, 0), 4+3, 0),
+	} {
+		var model AdaptiveDataModel
+		if err := model.UnmarshalBinary(state); !errors.Is(err, ErrCorruptInput) {
+			t.Errorf("AdaptiveDataModel.UnmarshalBinary(%s) error = %v, want ErrCorruptInput", name, err)
+		}
+	}
+
+	// A fresh bit model has bit_0_count 1 and bit_count 2.
+	bit_state, _ := initAdaptiveBitModel().MarshalBinary()
+	for name, state := range map[string][]byte{
+		"bit_0_count equal to bit_count": setWord(bit_state, 3, 2),
+		"bit_0_count above bit_count":    setWord(bit_state, 3, 3),
+		"update_cycle too long":          setWord(setWord(bit_state, 0, 1<<20), 1, 1<<20),
+	} {
+		var model AdaptiveBitModel
+		if err := model.UnmarshalBinary(state); !errors.Is(err, ErrCorruptInput) {
+			t.Errorf("AdaptiveBitModel.UnmarshalBinary(%s) error = %v, want ErrCorruptInput", name, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package FastAC
+
+import "testing"
+
+func TestModel_Bind(t *testing.T) {
+	const n = 20000
+	static_data := initStaticDataModel()
+	static_data.SetDistribution(5, []float64{0.5, 0.2, 0.1, 0.1, 0.1})
+	static_bit := initStaticBitModel()
+	static_bit.SetProbability0(0.3)
+
+	encoder := initArithmeticCodec(1<<16, nil)
+	decoder := initArithmeticCodec(1<<16, nil)
+	encoders := []Model{
+		initAdaptiveDataModel(5).Bind(encoder), static_data.Bind(encoder),
+		initAdaptiveBitModel().Bind(encoder), static_bit.Bind(encoder),
+	}
+	decoders := []Model{
+		initAdaptiveDataModel(5).Bind(decoder), static_data.Bind(decoder),
+		initAdaptiveBitModel().Bind(decoder), static_bit.Bind(decoder),
+	}
+
+	rg := initRandomGenerator(99)
+	data := make([]uint32, n)
+	encoder.StartEncoder()
+	for k := range data {
+		// The previous symbol picks the model, as a context-dependent coder would.
+		m := k % len(encoders)
+		if k > 0 {
+			m = int(data[k-1]) % len(encoders)
+		}
+		if m < 2 {
+			data[k] = rg.Integer(5)
+		} else {
+			data[k] = rg.Integer(2)
+		}
+		encoders[m].Encode(data[k])
+	}
+	code_bytes := encoder.StopEncoder()
+	copy(decoder.code_buffer, encoder.code_buffer[:code_bytes])
+
+	decoder.StartDecoder()
+	for k := range data {
+		m := k % len(decoders)
+		if k > 0 {
+			m = int(data[k-1]) % len(decoders)
+		}
+		if s := decoders[m].Decode(); s != data[k] {
+			t.Fatalf("symbol %d: decoded %d, want %d", k, s, data[k])
+		}
+	}
+	decoder.StopDecoder()
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Interface dispatch overhead versus direct calls - - - - - - - - - - - -
+
+func benchmarkModelData(b *testing.B) ([]uint16, *ArithmeticCodec) {
+	src := initRandomDataSource()
+	src.SetTruncatedGeometric(64, 4.0)
+	data := make([]uint16, 1<<16)
+	for k := range data {
+		data[k] = uint16(src.Data())
+	}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	return data, initArithmeticCodec(1<<18, nil)
+}
+
+func BenchmarkModel_DirectAdaptiveData(b *testing.B) {
+	data, codec := benchmarkModelData(b)
+	model := initAdaptiveDataModel(64)
+	for i := 0; i < b.N; i++ {
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_AdaptiveDataModel(uint32(s), model)
+		}
+		codec.StopEncoder()
+	}
+}
+
+func BenchmarkModel_InterfaceAdaptiveData(b *testing.B) {
+	data, codec := benchmarkModelData(b)
+	var model Model = initAdaptiveDataModel(64).Bind(codec)
+	for i := 0; i < b.N; i++ {
+		codec.StartEncoder()
+		for _, s := range data {
+			model.Encode(uint32(s))
+		}
+		codec.StopEncoder()
+	}
+}
+
+func BenchmarkModel_DirectAdaptiveBit(b *testing.B) {
+	data, codec := benchmarkModelData(b)
+	model := initAdaptiveBitModel()
+	for i := 0; i < b.N; i++ {
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_AdaptiveBitModel(uint32(s&1), model)
+		}
+		codec.StopEncoder()
+	}
+}
+
+func BenchmarkModel_InterfaceAdaptiveBit(b *testing.B) {
+	data, codec := benchmarkModelData(b)
+	var model Model = initAdaptiveBitModel().Bind(codec)
+	for i := 0; i < b.N; i++ {
+		codec.StartEncoder()
+		for _, s := range data {
+			model.Encode(uint32(s & 1))
+		}
+		codec.StopEncoder()
+	}
+}
@@ -0,0 +1,372 @@
+package FastAC
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// PPMEscape chooses how a PPMModel estimates the probability of an escape,
+// the symbol that tells the decoder a byte is new in a context.
+type PPMEscape uint32
+
+const (
+	// PPMC gives the escape a count equal to the number of distinct bytes
+	// seen in the context.
+	PPMC PPMEscape = iota
+	// PPMD does the same with half the weight, taking the other half from
+	// the bytes: each byte seen n times counts n - 1/2. It usually codes
+	// text a little better than PPMC.
+	PPMD
+)
+
+func (e PPMEscape) String() string {
+	switch e {
+	case PPMC:
+		return "PPMC"
+	case PPMD:
+		return "PPMD"
+	}
+	return fmt.Sprintf("PPMEscape(%d)", uint32(e))
+}
+
+const (
+	PPM__MaxOrder    = 7
+	PPM__MaxContexts = 1 << 18 // the model restarts when it holds more
+	PPM__CountLimit  = 1 << 13 // counts of a context are halved above it
+)
+
+// ppmContext holds the bytes seen after a context, in the order they first
+// appeared, and how often each was seen.
+type ppmContext struct {
+	symbols []byte
+	counts  []uint16
+	sum     uint32
+}
+
+func (c *ppmContext) add(symbol byte) {
+	c.sum++
+	for k, s := range c.symbols {
+		if s == symbol {
+			c.counts[k]++
+			if c.sum > PPM__CountLimit {
+				c.halve()
+			}
+			return
+		}
+	}
+	c.symbols = append(c.symbols, symbol)
+	c.counts = append(c.counts, 1)
+}
+
+func (c *ppmContext) halve() {
+	c.sum = 0
+	for k := range c.counts {
+		c.counts[k] = (c.counts[k] + 1) >> 1
+		c.sum += uint32(c.counts[k])
+	}
+}
+
+// PPMModel codes bytes by prediction by partial matching: it predicts each
+// byte from the statistics of the longest context, up to its order, that has
+// been seen before. When the byte is new in that context the model codes an
+// escape and tries the next shorter context, down to order 0 and finally to a
+// uniform distribution over all bytes, so it handles bytes never seen. Bytes
+// already ruled out by an escape are excluded from the shorter contexts.
+//
+// Counts are updated in the context that coded the byte and the longer ones
+// (update exclusion). Memory grows with the number of distinct contexts; when
+// they pass PPM__MaxContexts the model restarts from scratch.
+type PPMModel struct {
+	order        uint32
+	escape       PPMEscape
+	contexts     map[uint64]*ppmContext
+	max_contexts int
+	history      uint64 // the last bytes, the most recent in the low byte
+	seen         uint32 // bytes coded since the restart, up to order
+
+	// Bytes excluded while coding the current byte hold its stamp.
+	excluded     [256]uint32
+	stamp        uint32
+	num_excluded uint32
+
+	found [PPM__MaxOrder + 1]*ppmContext // contexts of the current byte
+
+	corrupt bool // the decoder reached a state no code produces
+}
+
+// NewPPMModel returns a model of order 0 to PPM__MaxOrder using escape.
+func NewPPMModel(order uint32, escape PPMEscape) (*PPMModel, error) {
+	if order > PPM__MaxOrder {
+		return nil, codecError("NewPPMModel", ErrInvalidContext, fmt.Sprintf("order %d", order))
+	}
+	if escape != PPMC && escape != PPMD {
+		return nil, codecError("NewPPMModel", ErrInvalidProbability, escape.String())
+	}
+	p := &PPMModel{order: order, escape: escape, max_contexts: PPM__MaxContexts}
+	p.Reset()
+	return p, nil
+}
+
+func initPPMModel(order uint32, escape PPMEscape) *PPMModel {
+	p, err := NewPPMModel(order, escape)
+	mustSucceed(err)
+	return p
+}
+
+// Reset forgets all contexts and clears Corrupt.
+func (p *PPMModel) Reset() {
+	p.restart()
+	p.corrupt = false
+}
+
+// Corrupt reports whether Decode_PPMModel has decoded an escape from every
+// byte, which only a corrupt code can give; the bytes it decodes from then
+// on are meaningless.
+func (p *PPMModel) Corrupt() bool {
+	return p.corrupt
+}
+
+func (p *PPMModel) restart() {
+	p.contexts = make(map[uint64]*ppmContext)
+	p.history, p.seen = 0, 0
+	p.excluded = [256]uint32{}
+	p.stamp = 0
+}
+
+// Contexts returns the number of contexts the model holds.
+func (p *PPMModel) Contexts() int {
+	return len(p.contexts)
+}
+
+func (p *PPMModel) context(order uint32) *ppmContext {
+	return p.contexts[uint64(order)<<56|p.history&(1<<(8*order)-1)]
+}
+
+// startSymbol clears the exclusions and returns the longest order to try.
+func (p *PPMModel) startSymbol() uint32 {
+	if p.stamp++; p.stamp == 0 {
+		p.excluded = [256]uint32{}
+		p.stamp = 1
+	}
+	p.num_excluded = 0
+	if p.seen < p.order {
+		return p.seen
+	}
+	return p.order
+}
+
+func (p *PPMModel) frequency(count uint16) uint32 {
+	if p.escape == PPMD {
+		return 2*uint32(count) - 1
+	}
+	return uint32(count)
+}
+
+// weigh returns the total and the escape frequency of c over the bytes not
+// excluded; the total is 0 when all are.
+func (p *PPMModel) weigh(c *ppmContext) (total, escape uint32) {
+	for k, s := range c.symbols {
+		if p.excluded[s] != p.stamp {
+			total += p.frequency(c.counts[k])
+			escape++
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return total + escape, escape
+}
+
+func (p *PPMModel) exclude(c *ppmContext) {
+	for _, s := range c.symbols {
+		if p.excluded[s] != p.stamp {
+			p.excluded[s] = p.stamp
+			p.num_excluded++
+		}
+	}
+}
+
+// update adds symbol to the contexts of orders above coded, creating those
+// not seen yet, and to the one that coded it, then shifts it into the
+// history.
+func (p *PPMModel) update(symbol byte, coded int, top uint32) {
+	for o := int(top); o >= 0 && o >= coded; o-- {
+		c := p.found[o]
+		if c == nil {
+			c = new(ppmContext)
+			p.contexts[uint64(o)<<56|p.history&(1<<(8*uint(o))-1)] = c
+		}
+		c.add(symbol)
+	}
+	p.history = p.history<<8 | uint64(symbol)
+	if p.seen < p.order {
+		p.seen++
+	}
+	if len(p.contexts) > p.max_contexts {
+		p.restart()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// Encode_PPMModel codes data, a byte, with the model.
+func (a *ArithmeticCodec) Encode_PPMModel(data uint32, M *PPMModel) {
+	symbol := byte(data)
+	top := M.startSymbol()
+	for o := int(top); o >= 0; o-- {
+		c := M.context(uint32(o))
+		M.found[o] = c
+		if c == nil {
+			continue
+		}
+		total, escape := M.weigh(c)
+		if total == 0 {
+			continue
+		}
+		cum := uint32(0)
+		for k, s := range c.symbols {
+			if M.excluded[s] == M.stamp {
+				continue
+			}
+			freq := M.frequency(c.counts[k])
+			if s == symbol {
+				a.encodeFrequency(cum, freq, total)
+				M.update(symbol, o, top)
+				return
+			}
+			cum += freq
+		}
+		a.encodeFrequency(total-escape, escape, total)
+		M.exclude(c)
+	}
+
+	// No context predicts the byte: code it among those not excluded.
+	rank := uint32(0)
+	for s := byte(0); s < symbol; s++ {
+		if M.excluded[s] != M.stamp {
+			rank++
+		}
+	}
+	a.encodeFrequency(rank, 1, 256-M.num_excluded)
+	M.update(symbol, -1, top)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_PPMModel(M *PPMModel) uint32 {
+	top := M.startSymbol()
+	for o := int(top); o >= 0; o-- {
+		c := M.context(uint32(o))
+		M.found[o] = c
+		if c == nil {
+			continue
+		}
+		total, escape := M.weigh(c)
+		if total == 0 {
+			continue
+		}
+		target := a.decodeTarget(total)
+		if target >= total-escape {
+			a.decodeFrequency(total-escape, escape, total)
+			M.exclude(c)
+			continue
+		}
+		cum := uint32(0)
+		for k, s := range c.symbols {
+			if M.excluded[s] == M.stamp {
+				continue
+			}
+			freq := M.frequency(c.counts[k])
+			if target < cum+freq {
+				a.decodeFrequency(cum, freq, total)
+				M.update(s, o, top)
+				return uint32(s)
+			}
+			cum += freq
+		}
+	}
+
+	n := 256 - M.num_excluded
+	if n == 0 {
+		M.corrupt = true
+		M.update(0, -1, top)
+		return 0
+	}
+	rank := a.decodeTarget(n)
+	a.decodeFrequency(rank, 1, n)
+	symbol := 0
+	for ; ; symbol++ {
+		if M.excluded[symbol] != M.stamp {
+			if rank == 0 {
+				break
+			}
+			rank--
+		}
+	}
+	M.update(byte(symbol), -1, top)
+	return uint32(symbol)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Compression - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// CompressPPM codes data with a PPMModel of the given order and escape. The
+// code starts with the settings and the length of data, so DecompressPPM
+// needs nothing else.
+func CompressPPM(data []byte, order uint32, escape PPMEscape) ([]byte, error) {
+	model, err := NewPPMModel(order, escape)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) > 0xFFFFFFFF {
+		return nil, codecError("CompressPPM", ErrInvalidBufferSize, fmt.Sprint(len(data)))
+	}
+	var code bytes.Buffer
+	codec := new(ArithmeticCodec)
+	codec.StartStreamEncoder(&code)
+	codec.PutBits(order, 3)
+	codec.PutBits(uint32(escape), 1)
+	codec.PutBits(uint32(len(data))>>16, 16)
+	codec.PutBits(uint32(len(data))&0xFFFF, 16)
+	for _, c := range data {
+		codec.Encode_PPMModel(uint32(c), model)
+	}
+	if _, err := codec.TryStopEncoder(); err != nil {
+		return nil, err
+	}
+	return code.Bytes(), nil
+}
+
+// DecompressPPM decodes a code returned by CompressPPM.
+func DecompressPPM(code []byte) ([]byte, error) {
+	codec, err := NewArithmeticCodec(uint32(len(code))+16, nil)
+	if err != nil {
+		return nil, err
+	}
+	copy(codec.code_buffer, code)
+	codec.StartDecoder()
+	defer codec.StopDecoder()
+
+	order := codec.GetBits(3)
+	escape := PPMEscape(codec.GetBits(1))
+	n := codec.GetBits(16)<<16 | codec.GetBits(16)
+	// A context gives no byte more than 1 - 2^-14 of the interval and an
+	// escape at most half, so a byte costs at least 2^-15 bits, which bounds
+	// the length of a genuine code.
+	if uint64(n) > uint64(len(code))<<18 {
+		return nil, codecError("DecompressPPM", ErrCorruptInput, "data length does not fit the code")
+	}
+	model, err := NewPPMModel(order, escape)
+	if err != nil {
+		return nil, err
+	}
+	// n may still be corrupt, so the output grows as it is decoded.
+	data := make([]byte, 0, decodedCapacity(n, len(code)))
+	for k := uint32(0); k < n; k++ {
+		data = append(data, byte(codec.Decode_PPMModel(model)))
+		if model.corrupt {
+			return nil, codecError("DecompressPPM", ErrCorruptInput, fmt.Sprintf("byte %d escaped from every byte", k))
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,186 @@
+package FastAC
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// generatedLog returns n bytes of log lines with timestamps, levels, ids and
+// durations, which repeat their structure but rarely their numbers.
+func generatedLog(n int, seed uint32) []byte {
+	levels := []string{"INFO", "INFO", "INFO", "DEBUG", "WARN", "ERROR"}
+	events := []string{"request served", "cache miss", "connection reset", "retrying upstream", "job finished"}
+	rg := initRandomGenerator(seed)
+	var log bytes.Buffer
+	seconds := uint32(0)
+	for log.Len() < n {
+		seconds += rg.Integer(5)
+		fmt.Fprintf(&log, "2026-10-18 %02d:%02d:%02d %-5s worker-%d %s id=%d in %dms\n",
+			seconds/3600%24, seconds/60%60, seconds%60, levels[rg.Integer(uint32(len(levels)))],
+			rg.Integer(8), events[rg.Integer(uint32(len(events)))], 10000+rg.Integer(90000), 1+rg.Integer(500))
+	}
+	return log.Bytes()[:n]
+}
+
+func TestPPMModel(t *testing.T) {
+	corpora := []struct {
+		name string
+		data []byte
+	}{
+		{"text", generatedText(100000, 25)},
+		{"log", generatedLog(100000, 25)},
+	}
+	for _, corpus := range corpora {
+		codec := initArithmeticCodec(uint32(len(corpus.data)), nil)
+		codec.StartEncoder()
+		order0 := initAdaptiveDataModel(256)
+		for _, c := range corpus.data {
+			codec.Encode_AdaptiveDataModel(uint32(c), order0)
+		}
+		size0 := codec.StopEncoder()
+
+		sizes := make(map[PPMEscape][PPM__MaxOrder + 1]int)
+		for _, escape := range []PPMEscape{PPMC, PPMD} {
+			for order := uint32(0); order <= PPM__MaxOrder; order++ {
+				code, err := CompressPPM(corpus.data, order, escape)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := DecompressPPM(code)
+				if err != nil || !bytes.Equal(decoded, corpus.data) {
+					t.Fatalf("%s, %v order %d: incorrect decoding (error %v)", corpus.name, escape, order, err)
+				}
+				s := sizes[escape]
+				s[order] = len(code)
+				sizes[escape] = s
+			}
+		}
+		t.Logf("%s: %d bytes, %d with AdaptiveDataModel, PPMC %v, PPMD %v", corpus.name, len(corpus.data), size0, sizes[PPMC], sizes[PPMD])
+
+		// Order 0 is about as good as the adaptive model, and order 3 far
+		// better; PPMD beats PPMC from order 2.
+		if s := sizes[PPMC]; s[0] > int(size0)+int(size0)/50 || 2*s[3] > int(size0) {
+			t.Errorf("%s: PPMC coded %d bytes in %d at order 0 and %d at order 3, AdaptiveDataModel in %d", corpus.name, len(corpus.data), s[0], s[3], size0)
+		}
+		for order := 2; order <= PPM__MaxOrder; order++ {
+			if sizes[PPMD][order] >= sizes[PPMC][order] {
+				t.Errorf("%s, order %d: PPMD used %d bytes, PPMC %d", corpus.name, order, sizes[PPMD][order], sizes[PPMC][order])
+			}
+		}
+	}
+
+	// Every byte, each new when it comes, then again in another order.
+	rg := initRandomGenerator(25)
+	var data []byte
+	for pass := 0; pass < 2; pass++ {
+		perm := make([]byte, 256)
+		for k := range perm {
+			perm[k] = byte(k)
+		}
+		for k := 255; k > 0; k-- {
+			j := rg.Integer(uint32(k + 1))
+			perm[k], perm[j] = perm[j], perm[k]
+		}
+		data = append(data, perm...)
+	}
+	for _, input := range [][]byte{data, {}, {'a'}, bytes.Repeat([]byte{0}, 10000)} {
+		code, err := CompressPPM(input, 3, PPMD)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded, err := DecompressPPM(code); err != nil || !bytes.Equal(decoded, input) {
+			t.Errorf("%d bytes: incorrect decoding (error %v)", len(input), err)
+		}
+	}
+
+	// The model restarts when it has too many contexts, the same way when
+	// encoding and decoding.
+	text := corpora[0].data[:20000]
+	codec := initArithmeticCodec(uint32(len(text)), nil)
+	model := initPPMModel(5, PPMC)
+	model.max_contexts = 1000
+	codec.StartEncoder()
+	encoder := model.Bind(codec)
+	restarts, contexts := 0, 0
+	for _, c := range text {
+		encoder.Encode(uint32(c))
+		if model.Contexts() > 1000 {
+			t.Fatalf("model holds %d contexts", model.Contexts())
+		}
+		if model.Contexts() < contexts {
+			restarts++
+		}
+		contexts = model.Contexts()
+	}
+	codec.StopEncoder()
+	if restarts == 0 {
+		t.Error("model never restarted")
+	}
+	model.Reset()
+	codec.StartDecoder()
+	decoder := model.Bind(codec)
+	for k, c := range text {
+		if got := decoder.Decode(); got != uint32(c) {
+			t.Fatalf("restarting model: byte %d decoded as %d, want %d", k, got, c)
+		}
+	}
+	codec.StopDecoder()
+
+	if _, err := NewPPMModel(PPM__MaxOrder+1, PPMC); !errors.Is(err, ErrInvalidContext) {
+		t.Errorf("NewPPMModel(order %d) error = %v, want ErrInvalidContext", PPM__MaxOrder+1, err)
+	}
+	// A header that claims 2^32 - 1 bytes, followed by no code.
+	var header bytes.Buffer
+	codec.StartStreamEncoder(&header)
+	codec.PutBits(2, 3)
+	codec.PutBits(uint32(PPMC), 1)
+	codec.PutBits(0xFFFF, 16)
+	codec.PutBits(0xFFFF, 16)
+	codec.StopEncoder()
+	if _, err := DecompressPPM(header.Bytes()); !errors.Is(err, ErrCorruptInput) {
+		t.Errorf("DecompressPPM(corrupt length) error = %v, want ErrCorruptInput", err)
+	}
+}
+
+func TestDecompressPPM_Corrupt(t *testing.T) {
+	// Every byte value occurs, so a run of escapes down to order 0 can
+	// exclude all 256 of them.
+	rg := initRandomGenerator(25)
+	data := make([]byte, 4000)
+	for k := range data {
+		data[k] = byte(rg.Integer(256))
+	}
+	for _, escape := range []PPMEscape{PPMC, PPMD} {
+		code, err := CompressPPM(data, 2, escape)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Code bytes of all ones decode as escapes from every context.
+		corrupt := append([]byte(nil), code...)
+		for k := len(code) / 2; k < len(code); k++ {
+			corrupt[k] = 0xFF
+		}
+		if _, err := DecompressPPM(corrupt); !errors.Is(err, ErrCorruptInput) {
+			t.Errorf("%v: DecompressPPM(escapes) error = %v, want ErrCorruptInput", escape, err)
+		}
+
+		// Random damage either decodes to some data or is rejected.
+		rejected := 0
+		for trial := 0; trial < 200; trial++ {
+			copy(corrupt, code)
+			for k := 0; k < 4; k++ {
+				corrupt[5+rg.Integer(uint32(len(code)-5))] = byte(rg.Integer(256))
+			}
+			if _, err := DecompressPPM(corrupt); err != nil {
+				if !errors.Is(err, ErrCorruptInput) {
+					t.Fatalf("%v: DecompressPPM(damaged) error = %v, want ErrCorruptInput", escape, err)
+				}
+				rejected++
+			}
+		}
+		t.Logf("%v: %d of 200 damaged codes rejected", escape, rejected)
+	}
+}
@@ -0,0 +1,108 @@
+package FastAC
+
+// Probabilities are reported as the coder uses them: the fixed-point widths
+// of the symbol intervals over BM__MaxCount or DM__MaxCount. They change
+// only when an adaptive model updates, not after every symbol.
+
+// Probability returns the probability of bit, 0 or 1.
+func (s *StaticBitModel) Probability(bit uint32) float64 {
+	return float64(bitWidth(bit, s.bit_0_prob)) / BM__MaxCount
+}
+
+// Distribution returns the probabilities of 0 and 1.
+func (s *StaticBitModel) Distribution() []float64 {
+	return []float64{s.Probability(0), s.Probability(1)}
+}
+
+// Probability returns the current probability of bit, 0 or 1.
+func (a *AdaptiveBitModel) Probability(bit uint32) float64 {
+	return float64(bitWidth(bit, a.bit_0_prob)) / BM__MaxCount
+}
+
+// Distribution returns the current probabilities of 0 and 1.
+func (a *AdaptiveBitModel) Distribution() []float64 {
+	return []float64{a.Probability(0), a.Probability(1)}
+}
+
+// Probability returns the current probability of bit, 0 or 1. Unlike the
+// other adaptive models, it changes after every bit.
+func (s *ShiftBitModel) Probability(bit uint32) float64 {
+	return float64(shiftWidth(bit, s.prob0())) / SM__MaxProb
+}
+
+// Distribution returns the current probabilities of 0 and 1.
+func (s *ShiftBitModel) Distribution() []float64 {
+	return []float64{s.Probability(0), s.Probability(1)}
+}
+
+// Probability returns the probability of bit, 0 or 1, in the selected
+// context.
+func (h *HistoryBitModel) Probability(bit uint32) float64 {
+	return float64(shiftWidth(bit, SM__MaxProb-h.p1())) / SM__MaxProb
+}
+
+// Distribution returns the probabilities of 0 and 1 in the selected context.
+func (h *HistoryBitModel) Distribution() []float64 {
+	return []float64{h.Probability(0), h.Probability(1)}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// dataDistribution returns the probability of every symbol of a distribution
+// indexed by symbol.
+func dataDistribution(distribution []uint32, data_symbols uint32) []float64 {
+	p := make([]float64, data_symbols)
+	for k := range p {
+		p[k] = float64(dataWidth(distribution, data_symbols-1, uint32(k))) / DM__MaxCount
+	}
+	return p
+}
+
+// Probability returns the probability of data.
+func (sdm *StaticDataModel) Probability(data uint32) float64 {
+	return float64(dataWidth(sdm.distribution, sdm.last_symbol, data)) / DM__MaxCount
+}
+
+// Distribution returns the probability of every symbol.
+func (sdm *StaticDataModel) Distribution() []float64 {
+	return dataDistribution(sdm.distribution, sdm.data_symbols)
+}
+
+// Probability returns the current probability of data.
+func (a *AdaptiveDataModel) Probability(data uint32) float64 {
+	return float64(dataWidth(a.distribution, a.last_symbol, data)) / DM__MaxCount
+}
+
+// Distribution returns the current probability of every symbol.
+func (a *AdaptiveDataModel) Distribution() []float64 {
+	return dataDistribution(a.distribution, a.data_symbols)
+}
+
+// Probability returns the current probability of data.
+func (a *SortedAdaptiveDataModel) Probability(data uint32) float64 {
+	return float64(dataWidth(a.distribution, a.last_symbol, a.rank[data])) / DM__MaxCount
+}
+
+// Distribution returns the current probability of every symbol, indexed by
+// symbol rather than rank.
+func (a *SortedAdaptiveDataModel) Distribution() []float64 {
+	p := make([]float64, a.data_symbols)
+	for k := range p {
+		p[k] = a.Probability(uint32(k))
+	}
+	return p
+}
+
+// Probability returns the current probability of data.
+func (a *LargeAdaptiveDataModel) Probability(data uint32) float64 {
+	return float64(a.symbol_count[data]) / float64(a.total_count)
+}
+
+// Distribution returns the current probability of every symbol.
+func (a *LargeAdaptiveDataModel) Distribution() []float64 {
+	p := make([]float64, a.data_symbols)
+	for k := range p {
+		p[k] = a.Probability(uint32(k))
+	}
+	return p
+}
@@ -0,0 +1,54 @@
+package FastAC
+
+import (
+	"math"
+	"testing"
+)
+
+func TestModel_Probability(t *testing.T) {
+	static_bit := initStaticBitModel()
+	static_bit.SetProbability0(0.25)
+	if p := static_bit.Distribution(); p[0] != 0.25 || p[1] != 0.75 {
+		t.Errorf("StaticBitModel.Distribution() = %v, want [0.25 0.75]", p)
+	}
+
+	// A bit model trained on 90% zeros learns about that probability.
+	bit_model := initAdaptiveBitModel()
+	codec := initArithmeticCodec(1<<16, nil)
+	codec.StartEncoder()
+	for k := 0; k < 10000; k++ {
+		codec.Encode_AdaptiveBitModel(uint32(k%10/9), bit_model)
+	}
+	if p := bit_model.Probability(0); math.Abs(p-0.9) > 0.01 {
+		t.Errorf("AdaptiveBitModel.Probability(0) = %v after 90%% zeros", p)
+	}
+
+	static_data := initStaticDataModel()
+	static_data.SetFrequencies([]uint32{1, 2, 1})
+	if p := static_data.Distribution(); p[0] != 0.25 || p[1] != 0.5 || p[2] != 0.25 {
+		t.Errorf("StaticDataModel.Distribution() = %v, want [0.25 0.5 0.25]", p)
+	}
+
+	data_model := initAdaptiveDataModel(100)
+	for k := 0; k < 30000; k++ {
+		codec.Encode_AdaptiveDataModel(uint32(k%4), data_model)
+	}
+	codec.StopEncoder()
+	sum := 0.0
+	for s, p := range data_model.Distribution() {
+		sum += p
+		if p != data_model.Probability(uint32(s)) {
+			t.Errorf("AdaptiveDataModel: Distribution()[%d] = %v, Probability = %v", s, p, data_model.Probability(uint32(s)))
+		}
+		// Probabilities and costs describe the same intervals.
+		if c := data_model.Cost(uint32(s)); math.Abs(c+math.Log2(p)) > 1e-12 {
+			t.Errorf("AdaptiveDataModel: Cost(%d) = %v, -log2 Probability = %v", s, c, -math.Log2(p))
+		}
+	}
+	if math.Abs(sum-1) > 1e-12 {
+		t.Errorf("AdaptiveDataModel distribution adds up to %v", sum)
+	}
+	if p := data_model.Probability(2); math.Abs(p-0.25) > 0.01 {
+		t.Errorf("AdaptiveDataModel.Probability(2) = %v after uniform symbols 0-3", p)
+	}
+}
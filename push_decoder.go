@@ -0,0 +1,132 @@
+package FastAC
+
+// PushDecoder decodes a stream whose code bytes arrive piece by piece. Code
+// bytes are passed to Write as they come in; a Decode_* call that would need
+// bytes not received yet returns ErrNeedInput and leaves the decoder and the
+// model untouched, so the same call can simply be repeated after the next
+// Write. Once all code has been written, Close lets the decoder finish the
+// last symbols, which read a few bytes past the end of the code.
+type PushDecoder struct {
+	codec   ArithmeticCodec
+	input   []byte // codec.ac_pointer is a suffix of input once started
+	started bool
+	closed  bool
+}
+
+// A single symbol never renormalizes by more than three bytes.
+const pushDecoderLookahead = 3
+
+func NewPushDecoder() *PushDecoder {
+	return new(PushDecoder)
+}
+
+// Reset discards all input so a new stream can be decoded.
+func (d *PushDecoder) Reset() {
+	d.codec.mode = Undefined
+	d.codec.ac_pointer = nil
+	d.input = d.input[:0]
+	d.started = false
+	d.closed = false
+}
+
+func (d *PushDecoder) Write(p []byte) (int, error) {
+	if d.closed {
+		return 0, codecError("Write", ErrWrongMode, "input is closed")
+	}
+	if !d.started {
+		d.input = append(d.input, p...)
+		return len(p), nil
+	}
+	// Keep the last byte read, which ac_pointer points at, and drop the rest
+	// once it is more than half the buffer.
+	pos := len(d.input) - len(d.codec.ac_pointer)
+	if pos > len(d.input)>>1 {
+		d.input = d.input[:copy(d.input, d.input[pos:])]
+		pos = 0
+	}
+	d.input = append(d.input, p...)
+	d.codec.ac_pointer = d.input[pos:]
+	return len(p), nil
+}
+
+// Close marks the end of the code.
+func (d *PushDecoder) Close() error {
+	d.closed = true
+	return nil
+}
+
+func (d *PushDecoder) ready() error {
+	if !d.started {
+		if len(d.input) < 4 && !d.closed {
+			return ErrNeedInput
+		}
+		if len(d.input) == 0 {
+			return codecError("StartDecoder", ErrCorruptInput, "no code")
+		}
+		d.codec.code_buffer = d.input
+		d.codec.buffer_size = uint32(len(d.input))
+		if err := d.codec.TryStartDecoder(); err != nil {
+			return err
+		}
+		d.started = true
+	}
+	if len(d.codec.ac_pointer) <= pushDecoderLookahead && !d.closed {
+		return ErrNeedInput
+	}
+	return d.corrupt()
+}
+
+// corrupt fails once a data model has found the code outside the decoder
+// interval, which only a corrupt code does.
+func (d *PushDecoder) corrupt() error {
+	if d.codec.corrupt {
+		return codecError("Decode", ErrCorruptInput, "code leaves the decoder interval")
+	}
+	return nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (d *PushDecoder) GetBit() (bool, error) {
+	if err := d.ready(); err != nil {
+		return false, err
+	}
+	return d.codec.GetBit(), nil
+}
+
+func (d *PushDecoder) GetBits(bits uint32) (uint32, error) {
+	if err := d.ready(); err != nil {
+		return 0, err
+	}
+	return d.codec.GetBits(bits), nil
+}
+
+func (d *PushDecoder) Decode_StaticBitModel(M *StaticBitModel) (uint32, error) {
+	if err := d.ready(); err != nil {
+		return 0, err
+	}
+	return d.codec.Decode_StaticBitModel(M), nil
+}
+
+func (d *PushDecoder) Decode_AdaptiveBitModel(M *AdaptiveBitModel) (uint32, error) {
+	if err := d.ready(); err != nil {
+		return 0, err
+	}
+	return d.codec.Decode_AdaptiveBitModel(M), nil
+}
+
+func (d *PushDecoder) Decode_StaticDataModel(M *StaticDataModel) (uint32, error) {
+	if err := d.ready(); err != nil {
+		return 0, err
+	}
+	s := d.codec.Decode_StaticDataModel(M)
+	return s, d.corrupt()
+}
+
+func (d *PushDecoder) Decode_AdaptiveDataModel(M *AdaptiveDataModel) (uint32, error) {
+	if err := d.ready(); err != nil {
+		return 0, err
+	}
+	s := d.codec.Decode_AdaptiveDataModel(M)
+	return s, d.corrupt()
+}
@@ -0,0 +1,133 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPushDecoder_MatchesOneShot(t *testing.T) {
+	const n = 50000
+	src := initRandomDataSource()
+	src.SetTruncatedGeometric(40, 3.0)
+	static_data := initStaticDataModel()
+	static_data.SetDistribution(40, src.probability())
+	static_bit := initStaticBitModel()
+	static_bit.SetProbability0(0.8)
+
+	encoder := initArithmeticCodec(1<<18, nil)
+	encoder.StartEncoder()
+	adaptive_data, adaptive_bit := initAdaptiveDataModel(40), initAdaptiveBitModel()
+	for k := 0; k < n; k++ {
+		s := src.Data()
+		switch k % 4 {
+		case 0:
+			encoder.Encode_AdaptiveDataModel(s, adaptive_data)
+		case 1:
+			encoder.Encode_StaticDataModel(s, static_data)
+		case 2:
+			encoder.Encode_AdaptiveBitModel(s&1, adaptive_bit)
+			encoder.Encode_StaticBitModel(s>>5, static_bit)
+		case 3:
+			encoder.PutBits(s, 6)
+		}
+	}
+	code := encoder.code_buffer[:encoder.StopEncoder()]
+
+	one_shot := initArithmeticCodec(uint32(len(code)), code)
+	one_shot.StartDecoder()
+	adaptive_data, adaptive_bit = initAdaptiveDataModel(40), initAdaptiveBitModel()
+	want := make([]uint32, 0, 5*n/4)
+	for k := 0; k < n; k++ {
+		switch k % 4 {
+		case 0:
+			want = append(want, one_shot.Decode_AdaptiveDataModel(adaptive_data))
+		case 1:
+			want = append(want, one_shot.Decode_StaticDataModel(static_data))
+		case 2:
+			want = append(want, one_shot.Decode_AdaptiveBitModel(adaptive_bit), one_shot.Decode_StaticBitModel(static_bit))
+		case 3:
+			want = append(want, one_shot.GetBits(6))
+		}
+	}
+	one_shot.StopDecoder()
+
+	// Feed the code in uneven pieces, retrying every call that runs dry.
+	decoder := NewPushDecoder()
+	adaptive_data, adaptive_bit = initAdaptiveDataModel(40), initAdaptiveBitModel()
+	rg := initRandomGenerator(7)
+	fed, need_input := 0, 0
+	got := make([]uint32, 0, len(want))
+	decode := func(f func() (uint32, error)) {
+		for {
+			s, err := f()
+			if err == nil {
+				got = append(got, s)
+				return
+			}
+			if !errors.Is(err, ErrNeedInput) {
+				t.Fatalf("decode error = %v", err)
+			}
+			need_input++
+			if fed == len(code) {
+				decoder.Close()
+				continue
+			}
+			next := fed + 1 + int(rg.Integer(9))
+			if next > len(code) {
+				next = len(code)
+			}
+			decoder.Write(code[fed:next])
+			fed = next
+		}
+	}
+	for k := 0; k < n; k++ {
+		switch k % 4 {
+		case 0:
+			decode(func() (uint32, error) { return decoder.Decode_AdaptiveDataModel(adaptive_data) })
+		case 1:
+			decode(func() (uint32, error) { return decoder.Decode_StaticDataModel(static_data) })
+		case 2:
+			decode(func() (uint32, error) { return decoder.Decode_AdaptiveBitModel(adaptive_bit) })
+			decode(func() (uint32, error) { return decoder.Decode_StaticBitModel(static_bit) })
+		case 3:
+			decode(func() (uint32, error) { return decoder.GetBits(6) })
+		}
+	}
+
+	if need_input == 0 {
+		t.Errorf("decoder never asked for more input")
+	}
+	for k := range want {
+		if got[k] != want[k] {
+			t.Fatalf("symbol %d: push decoder = %d, one-shot decoder = %d", k, got[k], want[k])
+		}
+	}
+}
+
+func TestPushDecoder_Corrupt(t *testing.T) {
+	static_data := initStaticDataModel()
+	static_data.SetDistribution(40, nil)
+	for name, decode := range map[string]func(*PushDecoder) (uint32, error){
+		"AdaptiveDataModel": func(d *PushDecoder) (uint32, error) {
+			return d.Decode_AdaptiveDataModel(initAdaptiveDataModel(40))
+		},
+		"StaticDataModel": func(d *PushDecoder) (uint32, error) { return d.Decode_StaticDataModel(static_data) },
+	} {
+		decoder := NewPushDecoder()
+		for k := 0; k < 200; k++ {
+			decoder.Write([]byte{0xFF})
+		}
+		decoder.Close()
+		var err error
+		for k := 0; k < 100 && err == nil; k++ {
+			_, err = decode(decoder)
+		}
+		if !errors.Is(err, ErrCorruptInput) {
+			t.Errorf("Decode_%s of a corrupt code: error = %v, want ErrCorruptInput", name, err)
+		}
+		// The decoder stays failed.
+		if _, err := decoder.GetBits(4); !errors.Is(err, ErrCorruptInput) {
+			t.Errorf("GetBits after a corrupt code: error = %v, want ErrCorruptInput", err)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package FastAC
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+)
+
+// ReferenceCodec is the FloatingPoint version: a textbook arithmetic coder
+// that narrows the interval [low, low + width) / 2^scale with exact
+// arbitrary-precision arithmetic instead of fixed-width registers. It uses
+// the same fixed-point model probabilities as ArithmeticCodec, so its output
+// is as short as those probabilities allow, and it keeps the ideal code
+// length, the sum of -log2 p over the coded symbols. It is meant for teaching
+// and as an oracle in tests: every symbol costs time proportional to the
+// length of the code so far.
+type ReferenceCodec struct {
+	codeBuffer
+	low, width big.Int
+	scale      uint
+
+	value       big.Int // decoder: the first value_bytes bytes of the code
+	value_bytes uint32
+
+	ideal_bits float64
+	t1, t2, t3 big.Int // scratch
+}
+
+func NewReferenceCodec(max_code_bytes uint32, user_buffer []byte) (*ReferenceCodec, error) {
+	codec := new(ReferenceCodec)
+	if err := codec.TrySetBuffer(max_code_bytes, user_buffer); err != nil {
+		return nil, err
+	}
+	return codec, nil
+}
+
+// IdealBits returns the ideal code length, in bits, of the symbols coded since
+// the encoder or decoder was started.
+func (a *ReferenceCodec) IdealBits() float64 {
+	return a.ideal_bits
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Interval arithmetic - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// narrow selects the subinterval [cum, cum + freq) / 2^shift of the current
+// interval.
+func (a *ReferenceCodec) narrow(cum, freq uint32, shift uint) {
+	a.low.Lsh(&a.low, shift)
+	a.t1.SetUint64(uint64(cum))
+	a.low.Add(&a.low, a.t1.Mul(&a.t1, &a.width))
+	a.width.Mul(&a.width, a.t1.SetUint64(uint64(freq)))
+	a.scale += shift
+
+	// Drop common factors of two so the numbers only grow with the
+	// information actually coded.
+	tz := a.width.TrailingZeroBits()
+	if a.low.Sign() != 0 {
+		if lz := a.low.TrailingZeroBits(); lz < tz {
+			tz = lz
+		}
+	}
+	if tz > a.scale {
+		tz = a.scale
+	}
+	a.low.Rsh(&a.low, tz)
+	a.width.Rsh(&a.width, tz)
+	a.scale -= tz
+
+	a.ideal_bits += float64(shift) - math.Log2(float64(freq))
+}
+
+// target returns floor(2^shift * (code - low) / width), the position of the
+// code within the current interval, reading code bytes until it is certain.
+// A corrupt code can point outside the interval; the result is clamped.
+func (a *ReferenceCodec) target(shift uint) uint32 {
+	max := uint32(1<<shift - 1)
+	for {
+		lo := a.position(&a.value, shift, 0)
+		if lo >= max {
+			return max
+		}
+		if a.value_bytes >= a.buffer_size {
+			return lo
+		}
+		// The rest of the code can add at most one unit in the last byte read.
+		a.t3.Add(&a.value, big.NewInt(1))
+		if hi := a.position(&a.t3, shift, 1); hi == lo {
+			return lo
+		}
+		a.value.Lsh(&a.value, 8)
+		a.value.Or(&a.value, a.t3.SetUint64(uint64(a.code_buffer[a.value_bytes])))
+		a.value_bytes++
+	}
+}
+
+// position computes floor((2^shift * (v / 2^(8 value_bytes) - low / 2^scale)
+// * 2^scale - bias) / width), where bias 1 excludes v itself.
+func (a *ReferenceCodec) position(v *big.Int, shift uint, bias int64) uint32 {
+	bits := 8 * uint(a.value_bytes)
+	a.t1.Lsh(v, a.scale)
+	a.t2.Lsh(&a.low, bits)
+	a.t1.Sub(&a.t1, &a.t2)
+	a.t1.Lsh(&a.t1, shift)
+	a.t1.Sub(&a.t1, big.NewInt(bias))
+	a.t2.Lsh(&a.width, bits)
+	if a.t1.Sign() < 0 {
+		return 0
+	}
+	a.t1.Quo(&a.t1, &a.t2)
+	if !a.t1.IsUint64() || a.t1.Uint64() > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(a.t1.Uint64())
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ReferenceCodec) PutBit(bit uint32) {
+	if bit > 0 {
+		bit = 1
+	}
+	a.narrow(bit, 1, 1)
+}
+
+func (a *ReferenceCodec) GetBit() bool {
+	bit := a.target(1)
+	a.narrow(bit, 1, 1)
+	return bit != 0
+}
+
+func (a *ReferenceCodec) PutBits(data, bits uint32) {
+	a.narrow(data, 1, uint(bits))
+}
+
+func (a *ReferenceCodec) GetBits(bits uint32) uint32 {
+	s := a.target(uint(bits))
+	a.narrow(s, 1, uint(bits))
+	return s
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ReferenceCodec) codeBit(bit, bit_0_prob uint32) {
+	if bit == 0 {
+		a.narrow(0, bit_0_prob, BM__LengthShift)
+	} else {
+		a.narrow(bit_0_prob, BM__MaxCount-bit_0_prob, BM__LengthShift)
+	}
+}
+
+func (a *ReferenceCodec) Encode_StaticBitModel(bit uint32, M *StaticBitModel) {
+	a.codeBit(bit, M.bit_0_prob)
+}
+
+func (a *ReferenceCodec) Decode_StaticBitModel(M *StaticBitModel) uint32 {
+	bit := uint32(0)
+	if a.target(BM__LengthShift) >= M.bit_0_prob {
+		bit = 1
+	}
+	a.codeBit(bit, M.bit_0_prob)
+	return bit
+}
+
+func (a *ReferenceCodec) Encode_AdaptiveBitModel(bit uint32, M *AdaptiveBitModel) {
+	a.codeBit(bit, M.bit_0_prob)
+	if bit == 0 {
+		M.bit_0_count++
+	}
+	M.bits_until_update--
+	if M.bits_until_update == 0 {
+		M.Update()
+	}
+}
+
+func (a *ReferenceCodec) Decode_AdaptiveBitModel(M *AdaptiveBitModel) uint32 {
+	bit := uint32(0)
+	if a.target(BM__LengthShift) >= M.bit_0_prob {
+		bit = 1
+	}
+	a.Encode_AdaptiveBitModel(bit, M)
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ReferenceCodec) codeData(op string, data uint32, distribution []uint32, last_symbol uint32) {
+	high := uint32(DM__MaxCount)
+	if data != last_symbol {
+		high = distribution[data+1]
+	}
+	if high == distribution[data] {
+		panic(errZeroProbability(op, data))
+	}
+	a.narrow(distribution[data], high-distribution[data], DM__LengthShift)
+}
+
+// Finds the last symbol whose cumulative count is not above the target.
+func (a *ReferenceCodec) findData(distribution []uint32, data_symbols uint32) uint32 {
+	t := a.target(DM__LengthShift)
+	s, n := uint32(0), data_symbols
+	for n > s+1 {
+		m := (s + n) >> 1
+		if distribution[m] > t {
+			n = m
+		} else {
+			s = m
+		}
+	}
+	return s
+}
+
+func (a *ReferenceCodec) Encode_StaticDataModel(data uint32, M *StaticDataModel) {
+	a.codeData("Encode_StaticDataModel", data, M.distribution, M.last_symbol)
+}
+
+func (a *ReferenceCodec) Decode_StaticDataModel(M *StaticDataModel) uint32 {
+	s := a.findData(M.distribution, M.data_symbols)
+	a.codeData("Decode_StaticDataModel", s, M.distribution, M.last_symbol)
+	return s
+}
+
+func (a *ReferenceCodec) Encode_AdaptiveDataModel(data uint32, M *AdaptiveDataModel) {
+	a.codeData("Encode_AdaptiveDataModel", data, M.distribution, M.last_symbol)
+	M.symbol_count[data]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update(a.mode == Encoder)
+	}
+}
+
+func (a *ReferenceCodec) Decode_AdaptiveDataModel(M *AdaptiveDataModel) uint32 {
+	s := a.findData(M.distribution, M.data_symbols)
+	a.Encode_AdaptiveDataModel(s, M)
+	return s
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Other Arithmetic_Codec implementations  - - - - - - - - - - - - - - - -
+
+func (a *ReferenceCodec) restart() {
+	a.low.SetUint64(0)
+	a.width.SetUint64(1)
+	a.scale = 0
+	a.ideal_bits = 0
+}
+
+func (a *ReferenceCodec) StartEncoder() {
+	mustSucceed(a.TryStartEncoder())
+}
+
+func (a *ReferenceCodec) TryStartEncoder() error {
+	if a.mode != Undefined {
+		return codecError("StartEncoder", ErrWrongMode, "cannot start encoder")
+	}
+	if a.buffer_size == 0 {
+		return codecError("StartEncoder", ErrInvalidBufferSize, "no code buffer set")
+	}
+	a.mode = Encoder
+	a.restart()
+	return nil
+}
+
+func (a *ReferenceCodec) StartDecoder() {
+	mustSucceed(a.TryStartDecoder())
+}
+
+func (a *ReferenceCodec) TryStartDecoder() error {
+	if a.mode != Undefined {
+		return codecError("StartDecoder", ErrWrongMode, "cannot start decoder")
+	}
+	if a.buffer_size == 0 {
+		return codecError("StartDecoder", ErrInvalidBufferSize, "no code buffer set")
+	}
+	a.mode = Decoder
+	a.restart()
+	a.value.SetUint64(0)
+	a.value_bytes = 0
+	return nil
+}
+
+func (a *ReferenceCodec) ReadFromFile(file *os.File) {
+	mustSucceed(a.TryReadFromFile(file))
+}
+
+// TryReadFromFile reads a code written by WriteToFile. Bytes of the buffer
+// past the code are left as they are: the decoder may read them, but
+// TryStopEncoder chose the code so that they cannot change the result.
+func (a *ReferenceCodec) TryReadFromFile(file *os.File) error {
+	if err := readCode(file, a.code_buffer, a.buffer_size); err != nil {
+		return err
+	}
+	return a.TryStartDecoder()
+}
+
+func (a *ReferenceCodec) StopEncoder() uint32 {
+	code_bytes, err := a.TryStopEncoder()
+	mustSucceed(err)
+	return code_bytes
+}
+
+// TryStopEncoder writes the shortest whole number of bytes c such that every
+// number starting with c lies in the final interval, so the decoder gives the
+// same result whatever follows the code in the buffer.
+func (a *ReferenceCodec) TryStopEncoder() (uint32, error) {
+	if a.mode != Encoder {
+		return 0, codecError("StopEncoder", ErrWrongMode, "invalid to stop encoder")
+	}
+	a.mode = Undefined
+
+	var m, high big.Int
+	for bits := uint(8); ; bits += 8 {
+		// m = ceil(low * 2^bits / 2^scale), then check (m + 1) / 2^bits is
+		// not beyond (low + width) / 2^scale.
+		m.Lsh(&a.low, bits)
+		m.Add(&m, a.t1.Sub(a.t1.Lsh(big.NewInt(1), a.scale), big.NewInt(1)))
+		m.Rsh(&m, a.scale)
+		high.Add(&a.low, &a.width)
+		high.Lsh(&high, bits)
+		a.t1.Add(&m, big.NewInt(1))
+		a.t1.Lsh(&a.t1, a.scale)
+		if a.t1.Cmp(&high) > 0 {
+			continue
+		}
+
+		code_bytes := uint32(bits / 8)
+		if code_bytes > a.buffer_size {
+			return 0, codecError("StopEncoder", ErrBufferOverflow, fmt.Sprint(code_bytes))
+		}
+		m.FillBytes(a.code_buffer[:code_bytes])
+		return code_bytes, nil
+	}
+}
+
+func (a *ReferenceCodec) WriteToFile(file *os.File) uint32 {
+	n, err := a.TryWriteToFile(file)
+	mustSucceed(err)
+	return n
+}
+
+func (a *ReferenceCodec) TryWriteToFile(file *os.File) (uint32, error) {
+	code_bytes, err := a.TryStopEncoder()
+	if err != nil {
+		return 0, err
+	}
+	return writeCode(file, a.code_buffer[:code_bytes])
+}
+
+func (a *ReferenceCodec) StopDecoder() {
+	mustSucceed(a.TryStopDecoder())
+}
+
+func (a *ReferenceCodec) TryStopDecoder() error {
+	if a.mode != Decoder {
+		return codecError("StopDecoder", ErrWrongMode, "invalid to stop decoder")
+	}
+	a.mode = Undefined
+	return nil
+}
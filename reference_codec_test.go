@@ -0,0 +1,126 @@
+package FastAC
+
+import "testing"
+
+// codeReferenceTestData codes the same mixed sequence of bits and symbols with
+// any codec version, returning what it coded or decoded.
+func codeReferenceTestData(codec Codec, mode Mode, data []uint32) []uint32 {
+	static_bit := initStaticBitModel()
+	static_bit.SetProbability0(0.9)
+	static_data := initStaticDataModel()
+	static_data.SetDistribution(40, nil)
+	bit_model, data_model := initAdaptiveBitModel(), initAdaptiveDataModel(300)
+
+	out := make([]uint32, len(data))
+	for k, w := range data {
+		var s uint32
+		switch k % 5 {
+		case 0:
+			if mode == Encoder {
+				s = w % 300
+				codec.Encode_AdaptiveDataModel(s, data_model)
+			} else {
+				s = codec.Decode_AdaptiveDataModel(data_model)
+			}
+		case 1:
+			if mode == Encoder {
+				s = 0
+				if w&0xF == 0 {
+					s = 1
+				}
+				codec.Encode_StaticBitModel(s, static_bit)
+			} else {
+				s = codec.Decode_StaticBitModel(static_bit)
+			}
+		case 2:
+			if mode == Encoder {
+				s = w >> 27 & 7
+				codec.PutBits(s, 3)
+			} else {
+				s = codec.GetBits(3)
+			}
+		case 3:
+			if mode == Encoder {
+				s = 0
+				if w&0xF0 == 0 {
+					s = 1
+				}
+				codec.Encode_AdaptiveBitModel(s, bit_model)
+			} else {
+				s = codec.Decode_AdaptiveBitModel(bit_model)
+			}
+		default:
+			if mode == Encoder {
+				s = w % 40
+				codec.Encode_StaticDataModel(s, static_data)
+			} else {
+				s = codec.Decode_StaticDataModel(static_data)
+			}
+		}
+		out[k] = s
+	}
+	return out
+}
+
+func TestReferenceCodec_RoundTrip(t *testing.T) {
+	rg := initRandomGenerator(8)
+	data := make([]uint32, 5000)
+	for k := range data {
+		data[k] = rg.Word()
+	}
+	// Fill the buffer with garbage so a code that needed the bytes after it
+	// would decode wrongly.
+	buffer := make([]byte, 1<<14)
+	for k := range buffer {
+		buffer[k] = byte(rg.Word())
+	}
+	codec := initCodec(FloatingPoint, uint32(len(buffer)), buffer).(*ReferenceCodec)
+
+	codec.StartEncoder()
+	want := codeReferenceTestData(codec, Encoder, data)
+	code_bytes := codec.StopEncoder()
+	ideal := codec.IdealBits()
+	if bits := 8 * float64(code_bytes); bits < ideal || bits > ideal+16 {
+		t.Errorf("code has %v bits, ideal length is %.1f bits", bits, ideal)
+	}
+
+	codec.StartDecoder()
+	got := codeReferenceTestData(codec, Decoder, data)
+	codec.StopDecoder()
+	for k := range want {
+		if got[k] != want[k] {
+			t.Fatalf("symbol %d: decoded %d, want %d", k, got[k], want[k])
+		}
+	}
+	if codec.IdealBits() != ideal {
+		t.Errorf("decoder ideal length %.1f, encoder %.1f", codec.IdealBits(), ideal)
+	}
+}
+
+// The integer codecs lose a little to truncation of the interval length; the
+// reference codec measures how much.
+func TestReferenceCodec_Oracle(t *testing.T) {
+	rg := initRandomGenerator(88)
+	data := make([]uint32, 20000)
+	for k := range data {
+		data[k] = rg.Word()
+	}
+
+	oracle := initCodec(FloatingPoint, 1<<16, nil).(*ReferenceCodec)
+	oracle.StartEncoder()
+	codeReferenceTestData(oracle, Encoder, data)
+	ideal := oracle.IdealBits()
+	oracle.StopEncoder()
+
+	for _, version := range []Version{Int3232, Int3264} {
+		codec := initCodec(version, 1<<16, nil)
+		codec.StartEncoder()
+		codeReferenceTestData(codec, Encoder, data)
+		bits := 8 * float64(codec.StopEncoder())
+		redundancy := (bits - ideal) / ideal
+		t.Logf("%v: %v bits, ideal %.1f, redundancy %.4f%%", version, bits, ideal, 100*redundancy)
+		if redundancy < -1e-6 || bits > ideal*1.001+64 {
+			t.Errorf("%v: %v bits is too far from the ideal %.1f bits", version, bits, ideal)
+		}
+	}
+}
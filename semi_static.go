@@ -0,0 +1,143 @@
+package FastAC
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// A StaticDataModel is coded as its number of symbols followed by the width
+// of every symbol's interval but the last, which is what remains of
+// DM__MaxCount. Each width is coded as its bit length, with an adaptive model
+// since most widths in a histogram are similar, followed by the bits after
+// the leading one.
+const distributionLengthSymbols = DM__LengthShift + 2
+
+// EncodeDistribution codes the distribution of M, exactly as quantized, so
+// that DecodeDistribution rebuilds an identical model.
+func (a *ArithmeticCodec) EncodeDistribution(M *StaticDataModel) {
+	a.PutBits(M.data_symbols-1, 11)
+	length_model := initAdaptiveDataModel(distributionLengthSymbols)
+	for k := uint32(0); k < M.last_symbol; k++ {
+		width := M.distribution[k+1] - M.distribution[k]
+		n := uint32(bits.Len32(width))
+		a.Encode_AdaptiveDataModel(n, length_model)
+		if n > 1 {
+			a.PutBits(width-1<<(n-1), n-1)
+		}
+	}
+}
+
+// DecodeDistribution sets M to a distribution coded by EncodeDistribution.
+// It fails, leaving M unchanged, if the widths add up to more than
+// DM__MaxCount.
+func (a *ArithmeticCodec) DecodeDistribution(M *StaticDataModel) error {
+	data_symbols := a.GetBits(11) + 1
+	if data_symbols < 2 {
+		return codecError("DecodeDistribution", ErrCorruptInput, "single symbol alphabet")
+	}
+	distribution := make([]uint32, data_symbols)
+	length_model := initAdaptiveDataModel(distributionLengthSymbols)
+	sum := uint32(0)
+	for k := uint32(1); k < data_symbols; k++ {
+		width := uint32(0)
+		if n := a.Decode_AdaptiveDataModel(length_model); n > 0 {
+			width = 1 << (n - 1)
+			if n > 1 {
+				width += a.GetBits(n - 1)
+			}
+		}
+		sum += width
+		if sum > DM__MaxCount {
+			return codecError("DecodeDistribution", ErrCorruptInput, "widths exceed DM__MaxCount")
+		}
+		distribution[k] = sum
+	}
+
+	M.setAlphabet(data_symbols, DM__LengthShift)
+	copy(M.distribution, distribution)
+	M.buildDecoderTable()
+	return nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// CompressSemiStatic codes data in two passes: it counts the symbols, builds
+// a StaticDataModel with SetFrequenciesWithZeros, and returns a code holding
+// the data length, the model and the data. Symbols must be below 2048.
+func CompressSemiStatic(data []uint16) ([]byte, error) {
+	data_symbols := uint32(2)
+	for _, s := range data {
+		if uint32(s) >= data_symbols {
+			data_symbols = uint32(s) + 1
+		}
+	}
+	if data_symbols > (1 << 11) {
+		return nil, codecError("CompressSemiStatic", ErrInvalidAlphabet, fmt.Sprint(data_symbols))
+	}
+	frequency := make([]uint32, data_symbols)
+	for _, s := range data {
+		frequency[s]++
+	}
+	model := NewStaticDataModel()
+	if len(data) == 0 {
+		frequency[0] = 1 // any valid model will do
+	}
+	if err := model.TrySetFrequenciesWithZeros(frequency); err != nil {
+		return nil, err
+	}
+
+	// Symbols cost at most 15 bits with this model, and the header at most
+	// 32 bits per symbol.
+	codec, err := NewArithmeticCodec(2*uint32(len(data))+4*data_symbols+64, nil)
+	if err != nil {
+		return nil, err
+	}
+	codec.StartEncoder()
+	codec.PutBits(uint32(len(data))>>16, 16)
+	codec.PutBits(uint32(len(data))&0xFFFF, 16)
+	codec.EncodeDistribution(model)
+	for _, s := range data {
+		codec.Encode_StaticDataModel(uint32(s), model)
+	}
+	code_bytes, err := codec.TryStopEncoder()
+	if err != nil {
+		return nil, err
+	}
+	return codec.code_buffer[:code_bytes:code_bytes], nil
+}
+
+// DecompressSemiStatic decodes a code returned by CompressSemiStatic.
+func DecompressSemiStatic(code []byte) ([]uint16, error) {
+	codec, err := NewArithmeticCodec(uint32(len(code))+16, nil)
+	if err != nil {
+		return nil, err
+	}
+	copy(codec.code_buffer, code)
+	codec.StartDecoder()
+	defer codec.StopDecoder()
+
+	n := codec.GetBits(16)<<16 | codec.GetBits(16)
+	model := NewStaticDataModel()
+	if err := codec.DecodeDistribution(model); err != nil {
+		return nil, err
+	}
+	// A model with a single symbol codes it in no bits at all, so the code
+	// does not bound n. The output grows as it is decoded instead of being
+	// allocated from a length that may be corrupt.
+	data := make([]uint16, 0, decodedCapacity(n, len(code)))
+	for k := uint32(0); k < n; k++ {
+		data = append(data, uint16(codec.Decode_StaticDataModel(model)))
+	}
+	return data, nil
+}
+
+// decodedCapacity returns the capacity to allocate for n symbols decoded
+// from code_bytes: all of them, unless a code of that size would average less
+// than a bit per symbol. Decoders that trust a length read from the code grow
+// their output past it as they decode.
+func decodedCapacity(n uint32, code_bytes int) uint32 {
+	if limit := 8 * uint64(code_bytes); uint64(n) > limit {
+		return uint32(limit)
+	}
+	return n
+}
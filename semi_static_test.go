@@ -0,0 +1,101 @@
+package FastAC
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestArithmeticCodec_EncodeDistribution(t *testing.T) {
+	frequency := make([]uint32, 700)
+	for k := range frequency {
+		if k%7 != 0 {
+			frequency[k] = uint32(k * k % 1000)
+		}
+	}
+	models := []*StaticDataModel{initStaticDataModel(), initStaticDataModel(), initStaticDataModel()}
+	models[0].SetFrequenciesWithZeros(frequency)
+	models[1].SetFrequencies(frequency[:5])
+	models[2].SetDistribution(3, []float64{0.9998, 0.0001, 0.0001})
+
+	codec := initArithmeticCodec(1<<14, nil)
+	codec.StartEncoder()
+	for _, model := range models {
+		codec.EncodeDistribution(model)
+	}
+	codec.StopEncoder()
+
+	codec.StartDecoder()
+	for _, model := range models {
+		decoded := initStaticDataModel()
+		if err := codec.DecodeDistribution(decoded); err != nil {
+			t.Fatalf("DecodeDistribution() error = %v", err)
+		}
+		if decoded.data_symbols != model.data_symbols {
+			t.Fatalf("decoded %d symbols, want %d", decoded.data_symbols, model.data_symbols)
+		}
+		for k := range model.distribution {
+			if decoded.distribution[k] != model.distribution[k] {
+				t.Fatalf("%d symbols: decoded model differs at %d", model.data_symbols, k)
+			}
+		}
+	}
+	codec.StopDecoder()
+}
+
+func TestCompressSemiStatic(t *testing.T) {
+	data := sortedTestData(1000, 6, 100000)
+	for _, data := range [][]uint16{data, nil, {0, 0, 0}, {2047}} {
+		code, err := CompressSemiStatic(data)
+		if err != nil {
+			t.Fatalf("CompressSemiStatic() error = %v", err)
+		}
+		decoded, err := DecompressSemiStatic(code)
+		if err != nil {
+			t.Fatalf("DecompressSemiStatic() error = %v", err)
+		}
+		if len(decoded) != len(data) {
+			t.Fatalf("decoded %d symbols, want %d", len(decoded), len(data))
+		}
+		for k := range data {
+			if decoded[k] != data[k] {
+				t.Fatalf("symbol %d decoded as %d, want %d", k, decoded[k], data[k])
+			}
+		}
+	}
+
+	// The source has 6 bits of entropy per symbol; the header is small.
+	code, _ := CompressSemiStatic(data)
+	if bits := 8 * float64(len(code)) / float64(len(data)); bits > 6.05 {
+		t.Errorf("semi-static code uses %.3f bits per symbol, want about 6", bits)
+	}
+
+	// A single symbol costs no bits, so the code is a few bytes whatever the
+	// length of the data.
+	for _, symbol := range []uint16{0, 700} {
+		data := make([]uint16, 5000000)
+		for k := range data {
+			data[k] = symbol
+		}
+		code, err := CompressSemiStatic(data)
+		if err != nil {
+			t.Fatalf("CompressSemiStatic(%d x %d) error = %v", len(data), symbol, err)
+		}
+		decoded, err := DecompressSemiStatic(code)
+		if err != nil || len(decoded) != len(data) {
+			t.Fatalf("DecompressSemiStatic(%d x %d) = %d symbols, error %v", len(data), symbol, len(decoded), err)
+		}
+		for k := range decoded {
+			if decoded[k] != symbol {
+				t.Fatalf("%d x %d: symbol %d decoded as %d", len(data), symbol, k, decoded[k])
+			}
+		}
+		t.Logf("%d x %d coded in %d bytes", len(data), symbol, len(code))
+	}
+
+	if _, err := CompressSemiStatic([]uint16{2048}); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("CompressSemiStatic(2048) error = %v, want ErrInvalidAlphabet", err)
+	}
+	if _, err := DecompressSemiStatic([]byte{0xFF, 0xFF, 0xFF, 0xFF}); !errors.Is(err, ErrCorruptInput) {
+		t.Errorf("DecompressSemiStatic(garbage) error = %v, want ErrCorruptInput", err)
+	}
+}
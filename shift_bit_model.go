@@ -0,0 +1,138 @@
+package FastAC
+
+import "fmt"
+
+const (
+	SM__LengthShift = 16 // bits of probability in a ShiftBitModel
+	SM__MaxProb     = 1 << SM__LengthShift
+)
+
+// ShiftBitModel is a bit model in the style of LZMA: instead of counting bits
+// and recomputing a probability every update cycle, it moves a 16-bit
+// probability towards each coded bit by a fixed fraction, 2^-rate, of the
+// distance. Updates cost a shift and an add, and the model forgets at a
+// constant speed: a low rate follows changes within a few dozen bits, a high
+// rate estimates stationary sources more precisely.
+//
+// With two rates the model keeps two probabilities and codes with their
+// average, which follows changes faster than the slow rate alone and settles
+// more precisely than the fast one.
+type ShiftBitModel struct {
+	fast_prob, slow_prob uint32 // probabilities of 0, over SM__MaxProb
+	fast_rate, slow_rate uint32 // equal in a single-rate model
+}
+
+// NewShiftBitModel returns a model with a single rate, from 1 to 15. LZMA uses
+// 5 with 11-bit probabilities; 4 or 5 suit rapidly changing bits and 7 or more
+// stationary ones.
+func NewShiftBitModel(rate uint32) (*ShiftBitModel, error) {
+	if rate < 1 || rate >= SM__LengthShift {
+		return nil, codecError("NewShiftBitModel", ErrInvalidAdaptation, fmt.Sprintf("rate %d", rate))
+	}
+	s := &ShiftBitModel{fast_rate: rate, slow_rate: rate}
+	s.Reset()
+	return s, nil
+}
+
+// NewDualRateShiftBitModel returns a model that mixes a fast and a slow rate,
+// each from 1 to 15, with fast_rate < slow_rate.
+func NewDualRateShiftBitModel(fast_rate, slow_rate uint32) (*ShiftBitModel, error) {
+	if fast_rate < 1 || fast_rate >= slow_rate || slow_rate >= SM__LengthShift {
+		return nil, codecError("NewDualRateShiftBitModel", ErrInvalidAdaptation, fmt.Sprintf("rates %d and %d", fast_rate, slow_rate))
+	}
+	s := &ShiftBitModel{fast_rate: fast_rate, slow_rate: slow_rate}
+	s.Reset()
+	return s, nil
+}
+
+func initShiftBitModel(rate uint32) *ShiftBitModel {
+	s, err := NewShiftBitModel(rate)
+	mustSucceed(err)
+	return s
+}
+
+func (s *ShiftBitModel) Reset() {
+	s.fast_prob = SM__MaxProb / 2
+	s.slow_prob = SM__MaxProb / 2
+}
+
+// Rates returns the model's rates, which are equal for a single-rate model.
+func (s *ShiftBitModel) Rates() (fast_rate, slow_rate uint32) {
+	return s.fast_rate, s.slow_rate
+}
+
+// prob0 returns the probability of 0 used for coding. It stays between 1 and
+// SM__MaxProb - 1, as an update never moves a probability past either bound.
+func (s *ShiftBitModel) prob0() uint32 {
+	return (s.fast_prob + s.slow_prob) >> 1
+}
+
+// Update moves the probabilities towards bit; the codec calls it after each
+// bit.
+func (s *ShiftBitModel) Update(bit uint32) {
+	if bit == 0 {
+		s.fast_prob += (SM__MaxProb - s.fast_prob) >> s.fast_rate
+		s.slow_prob += (SM__MaxProb - s.slow_prob) >> s.slow_rate
+	} else {
+		s.fast_prob -= s.fast_prob >> s.fast_rate
+		s.slow_prob -= s.slow_prob >> s.slow_rate
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Probability coding  - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// encodeBit codes bit given the probability of 0 over SM__MaxProb, which must
+// be between 1 and SM__MaxProb - 1.
+func (a *ArithmeticCodec) encodeBit(bit, prob0 uint32) {
+	x := prob0 * (a.length >> SM__LengthShift)
+	if bit == 0 {
+		a.length = x
+	} else {
+		init_base := a.base
+		a.base += x
+		a.length -= x
+		if init_base > a.base {
+			a.PropagateCarry()
+		}
+	}
+
+	if a.length < AC__MinLength {
+		a.RenormEncInterval()
+	}
+}
+
+func (a *ArithmeticCodec) decodeBit(prob0 uint32) uint32 {
+	x := prob0 * (a.length >> SM__LengthShift)
+	bit := uint32(0)
+	if a.value >= x {
+		bit = 1
+	}
+
+	if bit == 0 {
+		a.length = x
+	} else {
+		a.value -= x
+		a.length -= x
+	}
+
+	if a.length < AC__MinLength {
+		a.RenormDecInterval()
+	}
+	return bit
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Encode_ShiftBitModel(bit uint32, M *ShiftBitModel) {
+	a.encodeBit(bit, M.prob0())
+	M.Update(bit)
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_ShiftBitModel(M *ShiftBitModel) uint32 {
+	bit := a.decodeBit(M.prob0())
+	M.Update(bit)
+	return bit
+}
@@ -0,0 +1,88 @@
+package FastAC
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestShiftBitModel(t *testing.T) {
+	const n = 200000
+	data := nonstationaryBits(n, 1000)
+	single := initShiftBitModel(5)
+	dual, err := NewDualRateShiftBitModel(4, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, model := range []*ShiftBitModel{single, dual} {
+		fast_rate, slow_rate := model.Rates()
+		checkBitCode(t, fmt.Sprintf("rates %d, %d", fast_rate, slow_rate), model, data, nil)
+	}
+
+	// The shift models follow switching bits better than the counting model,
+	// and the dual-rate model loses less than the single rate on stationary
+	// ones.
+	if shift, counted := costBits(dual, data, nil), adaptiveBitBits(DefaultAdaptation, data); shift >= counted {
+		t.Errorf("switching bits: ShiftBitModel(4, 7) used %.0f bits, AdaptiveBitModel %.0f", shift, counted)
+	}
+	stationary := nonstationaryBits(n, 0)
+	single.Reset()
+	dual.Reset()
+	if d, s := costBits(dual, stationary, nil), costBits(single, stationary, nil); d >= s {
+		t.Errorf("stationary bits: ShiftBitModel(4, 7) used %.0f bits, ShiftBitModel(5) %.0f", d, s)
+	}
+
+	// Runs of one bit drive the probability to its bounds without reaching
+	// them.
+	single.Reset()
+	for k := 0; k < 1000; k++ {
+		single.Update(0)
+	}
+	if p := single.Probability(1); p <= 0 || p >= 0.001 {
+		t.Errorf("Probability(1) after 1000 zeros = %g", p)
+	}
+	for k := 0; k < 1000; k++ {
+		single.Update(1)
+	}
+	if p := single.Probability(0); p <= 0 || p >= 0.001 {
+		t.Errorf("Probability(0) after 1000 ones = %g", p)
+	}
+
+	for _, rates := range [][2]uint32{{0, 0}, {16, 0}, {5, 5}, {7, 4}, {4, 16}} {
+		var err error
+		if rates[1] == 0 {
+			_, err = NewShiftBitModel(rates[0])
+		} else {
+			_, err = NewDualRateShiftBitModel(rates[0], rates[1])
+		}
+		if !errors.Is(err, ErrInvalidAdaptation) {
+			t.Errorf("rates %v: error = %v, want ErrInvalidAdaptation", rates, err)
+		}
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func BenchmarkShiftBitModel(b *testing.B) {
+	for _, period := range []int{0, 10000, 1000} {
+		data := nonstationaryBits(SimulTests>>2, period)
+		for _, rates := range [][2]uint32{{5, 5}, {4, 7}} {
+			b.Run(fmt.Sprintf("switch%d/rates%d,%d", period, rates[0], rates[1]), func(b *testing.B) {
+				codec := initArithmeticCodec(SimulTests, nil)
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					model := initShiftBitModel(rates[0])
+					if rates[0] != rates[1] {
+						model, _ = NewDualRateShiftBitModel(rates[0], rates[1])
+					}
+					codec.StartEncoder()
+					for _, bit := range data {
+						codec.Encode_ShiftBitModel(bit, model)
+					}
+					b.ReportMetric(8*float64(codec.StopEncoder())/float64(len(data)), "bits/symbol")
+				}
+			})
+		}
+	}
+}
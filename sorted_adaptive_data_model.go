@@ -0,0 +1,173 @@
+package FastAC
+
+import "fmt"
+
+// SortedAdaptiveDataModel is the Int3232_Sorted variant of AdaptiveDataModel.
+// Symbols are kept in order of decreasing count and the distribution is laid
+// out by rank, so the decoder finds a symbol with a linear search from the
+// most frequent one and stops early on skewed sources. The order only changes
+// in Update, at the same point in the encoder and the decoder.
+type SortedAdaptiveDataModel struct {
+	distribution []uint32 // indexed by rank
+	symbol_count []uint32 // indexed by symbol
+	rank, symbol []uint32 // rank[symbol] and symbol[rank]
+
+	total_count, update_cycle, symbols_until_update uint32
+
+	data_symbols, last_symbol uint32
+}
+
+func NewSortedAdaptiveDataModel(number_of_symbols uint32) (*SortedAdaptiveDataModel, error) {
+	model := new(SortedAdaptiveDataModel)
+	if err := model.TrySetAlphabet(number_of_symbols); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func initSortedAdaptiveDataModel(number_of_symbols uint32) *SortedAdaptiveDataModel {
+	model, err := NewSortedAdaptiveDataModel(number_of_symbols)
+	mustSucceed(err)
+	return model
+}
+
+func (a *SortedAdaptiveDataModel) SetAlphabet(number_of_symbols uint32) {
+	mustSucceed(a.TrySetAlphabet(number_of_symbols))
+}
+
+func (a *SortedAdaptiveDataModel) TrySetAlphabet(number_of_symbols uint32) error {
+	if number_of_symbols < 2 || number_of_symbols > (1<<11) {
+		return codecError("SetAlphabet", ErrInvalidAlphabet, fmt.Sprint(number_of_symbols))
+	}
+
+	if a.data_symbols != number_of_symbols {
+		a.data_symbols = number_of_symbols
+		a.last_symbol = a.data_symbols - 1
+		memory := make([]uint32, 4*a.data_symbols)
+		a.distribution = memory[:a.data_symbols]
+		a.symbol_count = memory[a.data_symbols : 2*a.data_symbols]
+		a.rank = memory[2*a.data_symbols : 3*a.data_symbols]
+		a.symbol = memory[3*a.data_symbols:]
+	}
+	a.Reset()
+	return nil
+}
+
+func (a *SortedAdaptiveDataModel) Update() {
+	a.total_count += a.update_cycle
+	if a.total_count > DM__MaxCount {
+		a.total_count = 0
+		for n := uint32(0); n < a.data_symbols; n++ {
+			a.symbol_count[n] = (a.symbol_count[n] + 1) >> 1
+			a.total_count += a.symbol_count[n]
+		}
+	}
+
+	// Insertion sort: the order from the previous update is nearly right, and
+	// symbols with equal counts keep their places.
+	for r := uint32(1); r < a.data_symbols; r++ {
+		s := a.symbol[r]
+		c := a.symbol_count[s]
+		k := r
+		for ; k > 0 && a.symbol_count[a.symbol[k-1]] < c; k-- {
+			a.symbol[k] = a.symbol[k-1]
+			a.rank[a.symbol[k]] = k
+		}
+		a.symbol[k] = s
+		a.rank[s] = k
+	}
+
+	var sum uint32
+	scale := uint32(0x80000000 / a.total_count)
+	for r := uint32(0); r < a.data_symbols; r++ {
+		a.distribution[r] = (scale * sum) >> (31 - DM__LengthShift)
+		sum += a.symbol_count[a.symbol[r]]
+	}
+
+	a.update_cycle = (5 * a.update_cycle) >> 2
+	max_cycle := uint32((a.data_symbols + 6) << 3)
+	if a.update_cycle > max_cycle {
+		a.update_cycle = max_cycle
+	}
+	a.symbols_until_update = a.update_cycle
+}
+
+func (a *SortedAdaptiveDataModel) Reset() {
+	if a.data_symbols == 0 {
+		return
+	}
+
+	a.total_count = 0
+	a.update_cycle = a.data_symbols
+	for k := uint32(0); k < a.data_symbols; k++ {
+		a.symbol_count[k] = 1
+		a.rank[k], a.symbol[k] = k, k
+	}
+	a.Update()
+	a.symbols_until_update, a.update_cycle = (a.data_symbols+6)>>1, (a.data_symbols+6)>>1
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Encode_SortedAdaptiveDataModel(data uint32, M *SortedAdaptiveDataModel) {
+	var x uint32
+	var init_base uint32 = a.base
+	r := M.rank[data]
+
+	if r == M.last_symbol {
+		x = M.distribution[r] * (a.length >> DM__LengthShift)
+		a.base += x
+		a.length -= x
+	} else {
+		a.length >>= DM__LengthShift
+		x = M.distribution[r] * a.length
+		a.base += x
+		a.length = M.distribution[r+1]*a.length - x
+	}
+
+	if init_base > a.base {
+		a.PropagateCarry()
+	}
+
+	if a.length < AC__MinLength {
+		a.RenormEncInterval()
+	}
+
+	M.symbol_count[data]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update()
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) Decode_SortedAdaptiveDataModel(M *SortedAdaptiveDataModel) uint32 {
+	var r, x uint32
+	var y uint32 = a.length
+
+	a.length >>= DM__LengthShift
+	dv := a.value / a.length
+	for r != M.last_symbol && M.distribution[r+1] <= dv {
+		r++
+	}
+	x = M.distribution[r] * a.length
+	if r != M.last_symbol {
+		y = M.distribution[r+1] * a.length
+	}
+
+	a.value -= x
+	a.length = y - x
+
+	if a.length < AC__MinLength {
+		a.RenormDecInterval()
+	}
+
+	s := M.symbol[r]
+	M.symbol_count[s]++
+	M.symbols_until_update--
+	if M.symbols_until_update == 0 {
+		M.Update()
+	}
+	return s
+}
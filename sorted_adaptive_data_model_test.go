@@ -0,0 +1,108 @@
+package FastAC
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func sortedTestData(data_symbols uint32, entropy float64, n int) []uint16 {
+	src := initRandomDataSource()
+	src.SetTruncatedGeometric(data_symbols, entropy)
+	src.SetSeed(8315739)
+	data := make([]uint16, n)
+	fillDataBufferN(src, data)
+	return data
+}
+
+// fillDataBufferN is FillDataBuffer for buffers of any length.
+func fillDataBufferN(src *RandomDataSource, dataBuffer []uint16) {
+	src.ShuffleProbabilities()
+	for k := range dataBuffer {
+		dataBuffer[k] = uint16(src.Data())
+	}
+}
+
+func TestSortedAdaptiveDataModel_RoundTrip(t *testing.T) {
+	for _, data_symbols := range []uint32{2, 17, 300, 2048} {
+		data := sortedTestData(data_symbols, 0.5*math.Log2(float64(data_symbols)), 200000)
+
+		codec := initCodec(Int3232_Sorted, 1<<20, nil).(*ArithmeticCodec)
+		model := initSortedAdaptiveDataModel(data_symbols)
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_SortedAdaptiveDataModel(uint32(s), model)
+		}
+		sorted_bytes := codec.StopEncoder()
+
+		model.Reset()
+		codec.StartDecoder()
+		for k, s := range data {
+			if d := codec.Decode_SortedAdaptiveDataModel(model); d != uint32(s) {
+				t.Fatalf("%d symbols: symbol %d decoded as %d, want %d", data_symbols, k, d, s)
+			}
+		}
+		codec.StopDecoder()
+
+		// Sorting only permutes the distribution, so the code length must be
+		// close to the unsorted model's.
+		plain := initAdaptiveDataModel(data_symbols)
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_AdaptiveDataModel(uint32(s), plain)
+		}
+		plain_bytes := codec.StopEncoder()
+		if float64(sorted_bytes) > 1.01*float64(plain_bytes) {
+			t.Errorf("%d symbols: sorted model used %d bytes, unsorted %d", data_symbols, sorted_bytes, plain_bytes)
+		}
+	}
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+// - - Decoder speed: rank-ordered linear search versus decoder_table  - - - -
+
+func benchmarkDecodeSkewed(b *testing.B, sorted bool) {
+	for _, entropy := range []float64{1.0, 3.0} {
+		b.Run(fmt.Sprintf("256symbols/entropy%.0f", entropy), func(b *testing.B) {
+			data := sortedTestData(256, entropy, SimulTests>>2)
+			codec := initArithmeticCodec(SimulTests, nil)
+			plain, ranked := initAdaptiveDataModel(256), initSortedAdaptiveDataModel(256)
+
+			codec.StartEncoder()
+			for _, s := range data {
+				if sorted {
+					codec.Encode_SortedAdaptiveDataModel(uint32(s), ranked)
+				} else {
+					codec.Encode_AdaptiveDataModel(uint32(s), plain)
+				}
+			}
+			codec.StopEncoder()
+
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				codec.StartDecoder()
+				if sorted {
+					ranked.Reset()
+					for range data {
+						codec.Decode_SortedAdaptiveDataModel(ranked)
+					}
+				} else {
+					plain.Reset()
+					for range data {
+						codec.Decode_AdaptiveDataModel(plain)
+					}
+				}
+				codec.StopDecoder()
+			}
+		})
+	}
+}
+
+func BenchmarkDecode_AdaptiveDataModel(b *testing.B) {
+	benchmarkDecodeSkewed(b, false)
+}
+
+func BenchmarkDecode_SortedAdaptiveDataModel(b *testing.B) {
+	benchmarkDecodeSkewed(b, true)
+}
@@ -1,18 +1,30 @@
 package FastAC
 
+import "fmt"
+
 type StaticBitModel struct {
 	bit_0_prob uint32
 }
 
-func initStaticBitModel() *StaticBitModel {
+// NewStaticBitModel returns a bit model with both bits equally probable.
+func NewStaticBitModel() *StaticBitModel {
 	return &StaticBitModel{
 		bit_0_prob: 1 << (BM__LengthShift - 1),
 	}
 }
 
+func initStaticBitModel() *StaticBitModel {
+	return NewStaticBitModel()
+}
+
 func (s *StaticBitModel) SetProbability0(p0 float64) {
+	mustSucceed(s.TrySetProbability0(p0))
+}
+
+func (s *StaticBitModel) TrySetProbability0(p0 float64) error {
 	if p0 < 0.0001 || p0 > 0.9999 {
-		AC_Error("invalid bit probability")
+		return codecError("SetProbability0", ErrInvalidProbability, fmt.Sprint(p0))
 	}
 	s.bit_0_prob = uint32(p0 * (1 << BM__LengthShift))
+	return nil
 }
@@ -1,74 +1,192 @@
 package FastAC
 
+import (
+	"fmt"
+	"sort"
+)
+
 type StaticDataModel struct {
 	distribution, decoder_table []uint32
 
 	data_symbols, last_symbol, table_size, table_shift uint32
 }
 
-func initStaticDataModel() *StaticDataModel {
+// NewStaticDataModel returns an empty model; call SetDistribution before
+// coding with it.
+func NewStaticDataModel() *StaticDataModel {
 	return new(StaticDataModel)
 }
 
+func initStaticDataModel() *StaticDataModel {
+	return NewStaticDataModel()
+}
+
+// SetDistribution sets number_of_symbols probabilities, or a uniform
+// distribution when probability is nil.
 func (sdm *StaticDataModel) SetDistribution(number_of_symbols uint32, probability []float64) {
+	mustSucceed(sdm.TrySetDistribution(number_of_symbols, probability))
+}
+
+func (sdm *StaticDataModel) TrySetDistribution(number_of_symbols uint32, probability []float64) error {
 	if number_of_symbols < 2 || number_of_symbols > (1<<11) {
-		AC_Error("invalid number of data symbols")
+		return codecError("SetDistribution", ErrInvalidAlphabet, fmt.Sprint(number_of_symbols))
 	}
-
-	if sdm.data_symbols != number_of_symbols {
-		sdm.data_symbols = number_of_symbols
-		sdm.last_symbol = sdm.data_symbols - 1
-		sdm.distribution = nil
-
-		if sdm.data_symbols > 16 {
-			table_bits := uint32(3)
-			for sdm.data_symbols > (1 << (table_bits + 2)) {
-				table_bits++
+	if probability != nil && uint32(len(probability)) < number_of_symbols {
+		return codecError("SetDistribution", ErrInvalidProbability, "fewer probabilities than symbols")
+	}
+	if probability != nil {
+		sum := 0.0
+		for _, p := range probability[:number_of_symbols] {
+			if p < 0.0001 || p > 0.9999 {
+				return codecError("SetDistribution", ErrInvalidProbability, fmt.Sprint(p))
 			}
-			sdm.table_size = (1 << table_bits)
-			sdm.table_shift = DM__LengthShift - table_bits
-			sdm.distribution = make([]uint32, sdm.data_symbols+sdm.table_size+2)
-			sdm.decoder_table = sdm.distribution[sdm.data_symbols:]
-		} else {
-			sdm.decoder_table = nil
-			sdm.table_size, sdm.table_shift = 0, 0
-			sdm.distribution = make([]uint32, sdm.data_symbols)
+			sum += p
+		}
+		if sum < 0.9999 || sum > 1.0001 {
+			return codecError("SetDistribution", ErrInvalidProbability, "probabilities do not add up to 1")
 		}
 	}
 
-	s := uint32(0)
-	sum, p := 0.0, 1.0/float64(sdm.data_symbols)
+	sdm.setAlphabet(number_of_symbols, DM__LengthShift)
 
+	sum, p := 0.0, 1.0/float64(sdm.data_symbols)
 	for k := uint32(0); k < sdm.data_symbols; k++ {
 		if probability != nil {
 			p = probability[k]
 		}
+		sdm.distribution[k] = uint32(sum * (1 << DM__LengthShift))
+		sum += p
+	}
+	sdm.buildDecoderTable()
+	return nil
+}
 
-		if p < 0.0001 || p > 0.9999 {
-			AC_Error("invalid symbol probability")
+// SetFrequencies sets the distribution from symbol counts, such as a
+// histogram of the data, with one symbol per count (2 to 2048). The counts are
+// quantized with integer arithmetic only, so every platform builds the same
+// model, and every symbol keeps a non-empty interval even if its count is
+// zero.
+func (sdm *StaticDataModel) SetFrequencies(frequency []uint32) {
+	mustSucceed(sdm.trySetFrequencies("SetFrequencies", frequency, false, DM__LengthShift))
+}
+
+func (sdm *StaticDataModel) TrySetFrequencies(frequency []uint32) error {
+	return sdm.trySetFrequencies("SetFrequencies", frequency, false, DM__LengthShift)
+}
+
+// SetFrequenciesWithZeros is SetFrequencies, except that symbols with a zero
+// count get no interval at all and so cost nothing to the others. Such symbols
+// must not be coded: the encoders panic with ErrInvalidProbability, the last
+// symbol included.
+func (sdm *StaticDataModel) SetFrequenciesWithZeros(frequency []uint32) {
+	mustSucceed(sdm.trySetFrequencies("SetFrequenciesWithZeros", frequency, true, DM__LengthShift))
+}
+
+func (sdm *StaticDataModel) TrySetFrequenciesWithZeros(frequency []uint32) error {
+	return sdm.trySetFrequencies("SetFrequenciesWithZeros", frequency, true, DM__LengthShift)
+}
+
+// trySetFrequencies quantizes the counts to widths adding up to
+// 1 << length_shift.
+func (sdm *StaticDataModel) trySetFrequencies(op string, frequency []uint32, allow_zero bool, length_shift uint32) error {
+	number_of_symbols := uint32(len(frequency))
+	if number_of_symbols < 2 || number_of_symbols > (1<<11) {
+		return codecError(op, ErrInvalidAlphabet, fmt.Sprint(number_of_symbols))
+	}
+	total, used := uint64(0), uint64(0)
+	for _, f := range frequency {
+		total += uint64(f)
+		if f != 0 || !allow_zero {
+			used++
 		}
+	}
+	if total == 0 {
+		return codecError(op, ErrInvalidProbability, "all frequencies are zero")
+	}
 
-		sdm.distribution[k] = uint32(sum * (1 << DM__LengthShift))
-		sum += p
-		if sdm.table_size == 0 {
+	sdm.setAlphabet(number_of_symbols, length_shift)
+
+	// Every used symbol gets one unit, and the rest of the scale is shared in
+	// proportion to the counts. The units lost to rounding down go to the
+	// largest remainders, ties to the lower symbol.
+	share := uint64(1)<<length_shift - used
+	quantized := make([]uint32, number_of_symbols)
+	remainder := make([]uint64, number_of_symbols)
+	left := uint64(1) << length_shift
+	for k, f := range frequency {
+		if f == 0 && allow_zero {
 			continue
 		}
-		w := sdm.distribution[k] >> sdm.table_shift
-		for s < w {
-			s++
-			sdm.decoder_table[s] = k - 1
+		q := uint64(f) * share
+		quantized[k] = 1 + uint32(q/total)
+		remainder[k] = q % total
+		left -= uint64(quantized[k])
+	}
+	order := make([]uint32, number_of_symbols)
+	for k := range order {
+		order[k] = uint32(k)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainder[order[i]] > remainder[order[j]]
+	})
+	for _, k := range order[:left] {
+		quantized[k]++
+	}
+
+	sum := uint32(0)
+	for k, q := range quantized {
+		sdm.distribution[k] = sum
+		sum += q
+	}
+	sdm.buildDecoderTable()
+	return nil
+}
+
+// setAlphabet sizes the model for number_of_symbols symbols, with a decoder
+// table for widths over 1 << length_shift, keeping its memory when the size
+// does not change.
+func (sdm *StaticDataModel) setAlphabet(number_of_symbols, length_shift uint32) {
+	if sdm.data_symbols == number_of_symbols {
+		return
+	}
+	sdm.data_symbols = number_of_symbols
+	sdm.last_symbol = sdm.data_symbols - 1
+	sdm.distribution = nil
+
+	if sdm.data_symbols > 16 {
+		table_bits := uint32(3)
+		for sdm.data_symbols > (1 << (table_bits + 2)) {
+			table_bits++
 		}
+		sdm.table_size = (1 << table_bits)
+		sdm.table_shift = length_shift - table_bits
+		sdm.distribution = make([]uint32, sdm.data_symbols+sdm.table_size+2)
+		sdm.decoder_table = sdm.distribution[sdm.data_symbols:]
+	} else {
+		sdm.decoder_table = nil
+		sdm.table_size, sdm.table_shift = 0, 0
+		sdm.distribution = make([]uint32, sdm.data_symbols)
 	}
+}
 
-	if sdm.table_size != 0 {
-		sdm.decoder_table[0] = 0
-		for s < sdm.table_size {
+// buildDecoderTable fills decoder_table from the distribution. It fills the
+// entry after table_size too, which the decoder reads when the code falls in
+// the rounding remainder of the last symbol.
+func (sdm *StaticDataModel) buildDecoderTable() {
+	if sdm.table_size == 0 {
+		return
+	}
+	s := uint32(0)
+	for k := uint32(0); k < sdm.data_symbols; k++ {
+		w := sdm.distribution[k] >> sdm.table_shift
+		for s < w {
 			s++
-			sdm.decoder_table[s] = sdm.data_symbols - 1
+			sdm.decoder_table[s] = k - 1
 		}
 	}
-
-	if sum < 0.9999 || sum > 1.0001 {
-		AC_Error("invalid probabilities")
+	sdm.decoder_table[0] = 0
+	for s <= sdm.table_size {
+		s++
+		sdm.decoder_table[s] = sdm.data_symbols - 1
 	}
 }
@@ -0,0 +1,100 @@
+package FastAC
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStaticDataModel_SetFrequencies(t *testing.T) {
+	model := initStaticDataModel()
+
+	// Each symbol gets one unit plus its share of the other 32766, rounded
+	// down; the unit left over goes to symbol 0, which ties symbol 1 on the
+	// remainder.
+	model.SetFrequencies([]uint32{3, 1})
+	if got, want := model.distribution[1], uint32(2+(DM__MaxCount-2)*3/4); got != want {
+		t.Errorf("SetFrequencies(3, 1): distribution[1] = %d, want %d", got, want)
+	}
+
+	// A symbol seen once among billions keeps a non-empty interval.
+	model.SetFrequencies([]uint32{0xFFFFFFFF, 1, 0, 0xFFFFFFFF})
+	for k := uint32(0); k < model.last_symbol; k++ {
+		if model.distribution[k+1] <= model.distribution[k] {
+			t.Errorf("symbol %d has an empty interval: %v", k, model.distribution)
+		}
+	}
+
+	model.SetFrequenciesWithZeros([]uint32{0xFFFFFFFF, 1, 0, 0xFFFFFFFF})
+	if model.distribution[3] != model.distribution[2] {
+		t.Errorf("zero count symbol 2 has a non-empty interval: %v", model.distribution)
+	}
+
+	if err := model.TrySetFrequencies([]uint32{0, 0, 0}); !errors.Is(err, ErrInvalidProbability) {
+		t.Errorf("TrySetFrequencies(all zero) error = %v, want ErrInvalidProbability", err)
+	}
+	if err := model.TrySetFrequencies(make([]uint32, 1<<11+1)); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("TrySetFrequencies(2049 symbols) error = %v, want ErrInvalidAlphabet", err)
+	}
+}
+
+func TestStaticDataModel_ZeroFrequencyRoundTrip(t *testing.T) {
+	const n = 8000
+	rg := initRandomGenerator(11)
+	// Only multiples of 3 occur, with a skewed histogram.
+	frequency := make([]uint32, 300)
+	data := make([]uint32, n)
+	for k := range data {
+		data[k] = 3 * (rg.Integer(100) * rg.Integer(100) / 100)
+		frequency[data[k]]++
+	}
+
+	for _, version := range []Version{Int3232, Int3264, FloatingPoint} {
+		model := initStaticDataModel()
+		model.SetFrequenciesWithZeros(frequency)
+		codec := initCodec(version, 2*n, nil)
+		codec.StartEncoder()
+		for _, s := range data {
+			codec.Encode_StaticDataModel(s, model)
+		}
+		codec.StopEncoder()
+
+		codec.StartDecoder()
+		for k, s := range data {
+			if d := codec.Decode_StaticDataModel(model); d != s {
+				t.Fatalf("%v: symbol %d decoded as %d, want %d", version, k, d, s)
+			}
+		}
+		codec.StopDecoder()
+	}
+
+	// Symbol 299, the last, has no interval either; it would otherwise be coded
+	// in the rounding remainder of the others.
+	model := initStaticDataModel()
+	model.SetFrequenciesWithZeros(frequency)
+	model64 := NewStaticDataModel64()
+	model64.SetFrequenciesWithZeros(frequency)
+	wantPanic := func(name, op string, encode func()) {
+		defer func() {
+			var ce *CodecError
+			if err, _ := recover().(error); !errors.Is(err, ErrInvalidProbability) || !errors.As(err, &ce) || ce.Op != op {
+				t.Errorf("%s: recovered %v, want ErrInvalidProbability from %s", name, err, op)
+			}
+		}()
+		encode()
+	}
+	for _, version := range []Version{Int3232, Int3264, FloatingPoint} {
+		for _, s := range []uint32{1, 299} {
+			codec := initCodec(version, 1<<10, nil)
+			codec.StartEncoder()
+			wantPanic(fmt.Sprintf("%v, coding zero probability symbol %d", version, s), "Encode_StaticDataModel", func() {
+				codec.Encode_StaticDataModel(s, model)
+			})
+			if codec64, ok := codec.(*ArithmeticCodec64); ok {
+				wantPanic(fmt.Sprintf("StaticDataModel64, coding zero probability symbol %d", s), "Encode_StaticDataModel64", func() {
+					codec64.Encode_StaticDataModel64(s, model64)
+				})
+			}
+		}
+	}
+}
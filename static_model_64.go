@@ -0,0 +1,83 @@
+package FastAC
+
+import "fmt"
+
+// StaticBitModel64 is a StaticBitModel with its probability over
+// BM64__MaxCount, for the Int3264 codec. It can give a bit a probability as
+// small as 2^-24 instead of 10^-4.
+type StaticBitModel64 struct {
+	bit_0_prob uint32
+}
+
+// NewStaticBitModel64 returns a bit model with both bits equally probable.
+func NewStaticBitModel64() *StaticBitModel64 {
+	return &StaticBitModel64{
+		bit_0_prob: 1 << (BM64__LengthShift - 1),
+	}
+}
+
+func (s *StaticBitModel64) SetProbability0(p0 float64) {
+	mustSucceed(s.TrySetProbability0(p0))
+}
+
+// TrySetProbability0 accepts probabilities that leave both bits at least
+// 1 / BM64__MaxCount.
+func (s *StaticBitModel64) TrySetProbability0(p0 float64) error {
+	if !(p0 >= 1.0/BM64__MaxCount && p0 <= 1-1.0/BM64__MaxCount) {
+		return codecError("SetProbability0", ErrInvalidProbability, fmt.Sprint(p0))
+	}
+	s.bit_0_prob = uint32(p0 * BM64__MaxCount)
+	return nil
+}
+
+// Probability returns the probability of bit, 0 or 1.
+func (s *StaticBitModel64) Probability(bit uint32) float64 {
+	if bit == 0 {
+		return float64(s.bit_0_prob) / BM64__MaxCount
+	}
+	return float64(BM64__MaxCount-s.bit_0_prob) / BM64__MaxCount
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// StaticDataModel64 is a StaticDataModel with its distribution over
+// DM64__MaxCount, for the Int3264 codec. Its widths are set from symbol
+// counts, as with StaticDataModel.SetFrequencies.
+type StaticDataModel64 struct {
+	model StaticDataModel // the same layout, with finer widths
+}
+
+// NewStaticDataModel64 returns an empty model; call SetFrequencies before
+// coding with it.
+func NewStaticDataModel64() *StaticDataModel64 {
+	return new(StaticDataModel64)
+}
+
+// SetFrequencies sets the distribution from symbol counts, one per symbol
+// (2 to 2048); every symbol gets at least 1 / DM64__MaxCount.
+func (sdm *StaticDataModel64) SetFrequencies(frequency []uint32) {
+	mustSucceed(sdm.TrySetFrequencies(frequency))
+}
+
+func (sdm *StaticDataModel64) TrySetFrequencies(frequency []uint32) error {
+	return sdm.model.trySetFrequencies("SetFrequencies", frequency, false, DM64__LengthShift)
+}
+
+// SetFrequenciesWithZeros is SetFrequencies, except that symbols with a zero
+// count get no interval and must not be coded.
+func (sdm *StaticDataModel64) SetFrequenciesWithZeros(frequency []uint32) {
+	mustSucceed(sdm.TrySetFrequenciesWithZeros(frequency))
+}
+
+func (sdm *StaticDataModel64) TrySetFrequenciesWithZeros(frequency []uint32) error {
+	return sdm.model.trySetFrequencies("SetFrequenciesWithZeros", frequency, true, DM64__LengthShift)
+}
+
+// Probability returns the probability of data.
+func (sdm *StaticDataModel64) Probability(data uint32) float64 {
+	m := &sdm.model
+	if data == m.last_symbol {
+		return float64(DM64__MaxCount-m.distribution[data]) / DM64__MaxCount
+	}
+	return float64(m.distribution[data+1]-m.distribution[data]) / DM64__MaxCount
+}
@@ -0,0 +1,252 @@
+package FastAC
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Streams are a sequence of frames, each holding up to StreamChunkSize bytes
+// coded with a fresh codec but a model that carries over from the previous
+// frame:
+//
+//	uvarint(data bytes) uvarint(code bytes) code
+//
+// A frame with zero data bytes ends the stream.
+const StreamChunkSize = 1 << 16
+
+// Worst case is a little under 16 bits per byte for a 256 symbol model.
+const streamCodeBufferSize = 2*StreamChunkSize + 16
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// Writer compresses bytes written to it and writes the frames to an
+// underlying io.Writer.
+type Writer struct {
+	w      io.Writer
+	codec  ArithmeticCodec
+	model  *AdaptiveDataModel
+	data   []byte
+	header [2 * binary.MaxVarintLen32]byte
+	err    error
+	closed bool
+}
+
+// NewWriter returns a Writer that codes bytes with model, or with a fresh 256
+// symbol AdaptiveDataModel when model is nil. The model is reset, and must
+// have at least 256 symbols.
+func NewWriter(w io.Writer, model *AdaptiveDataModel) (*Writer, error) {
+	model, err := streamModel("NewWriter", model)
+	if err != nil {
+		return nil, err
+	}
+	z := &Writer{model: model, data: make([]byte, 0, StreamChunkSize)}
+	if err := z.codec.TrySetBuffer(streamCodeBufferSize, nil); err != nil {
+		return nil, err
+	}
+	z.Reset(w)
+	return z, nil
+}
+
+func streamModel(op string, model *AdaptiveDataModel) (*AdaptiveDataModel, error) {
+	if model == nil {
+		return NewAdaptiveDataModel(256)
+	}
+	if model.data_symbols < 256 {
+		return nil, codecError(op, ErrInvalidAlphabet, fmt.Sprint(model.data_symbols))
+	}
+	return model, nil
+}
+
+// Reset discards any pending data, resets the model and makes z write a new
+// stream to w.
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.data = z.data[:0]
+	z.err = nil
+	z.closed = false
+	z.model.Reset()
+}
+
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, codecError("Write", ErrWrongMode, "writer is closed")
+	}
+	n := 0
+	for len(p) > 0 {
+		k := copy(z.data[len(z.data):cap(z.data)], p)
+		z.data = z.data[:len(z.data)+k]
+		n += k
+		p = p[k:]
+		if len(z.data) == cap(z.data) {
+			if err := z.writeFrame(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush codes any pending data as a frame and writes it out. Data written
+// before Flush can be decoded without waiting for more input.
+func (z *Writer) Flush() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return codecError("Flush", ErrWrongMode, "writer is closed")
+	}
+	if len(z.data) == 0 {
+		return nil
+	}
+	return z.writeFrame()
+}
+
+// Close flushes pending data and writes the end of stream frame. It does not
+// close the underlying writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	if err := z.Flush(); err != nil {
+		return err
+	}
+	z.closed = true
+	return z.writeFrame()
+}
+
+func (z *Writer) writeFrame() error {
+	var code_bytes uint32
+	var err error
+	if len(z.data) > 0 {
+		z.codec.StartEncoder()
+		for _, b := range z.data {
+			z.codec.Encode_AdaptiveDataModel(uint32(b), z.model)
+		}
+		code_bytes, err = z.codec.TryStopEncoder()
+	}
+	if err == nil {
+		h := binary.PutUvarint(z.header[:], uint64(len(z.data)))
+		h += binary.PutUvarint(z.header[h:], uint64(code_bytes))
+		if _, err = z.w.Write(z.header[:h]); err == nil {
+			_, err = z.w.Write(z.codec.code_buffer[:code_bytes])
+		}
+	}
+	z.data = z.data[:0]
+	z.err = err
+	return err
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Reader decompresses a stream written by Writer.
+type Reader struct {
+	r     byteReader
+	buf   *bufio.Reader // used when the source is not already a byteReader
+	codec ArithmeticCodec
+	model *AdaptiveDataModel
+	data  []byte
+	next  []byte // decoded bytes not yet returned by Read
+	err   error
+}
+
+// NewReader returns a Reader that decodes a stream from r. model must match
+// the one given to NewWriter: nil, or a model with the same alphabet.
+func NewReader(r io.Reader, model *AdaptiveDataModel) (*Reader, error) {
+	model, err := streamModel("NewReader", model)
+	if err != nil {
+		return nil, err
+	}
+	z := &Reader{model: model, data: make([]byte, StreamChunkSize)}
+	if err := z.codec.TrySetBuffer(streamCodeBufferSize, nil); err != nil {
+		return nil, err
+	}
+	z.Reset(r)
+	return z, nil
+}
+
+// Reset discards any buffered data, resets the model and makes z read a new
+// stream from r.
+func (z *Reader) Reset(r io.Reader) {
+	if br, ok := r.(byteReader); ok {
+		z.r = br
+	} else if z.buf != nil {
+		z.buf.Reset(r)
+		z.r = z.buf
+	} else {
+		z.buf = bufio.NewReader(r)
+		z.r = z.buf
+	}
+	z.next = nil
+	z.err = nil
+	z.model.Reset()
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.next) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.readFrame()
+	}
+	n := copy(p, z.next)
+	z.next = z.next[n:]
+	return n, nil
+}
+
+func (z *Reader) readFrame() error {
+	data_bytes, err := binary.ReadUvarint(z.r)
+	if err != nil {
+		return streamReadError(err)
+	}
+	code_bytes, err := binary.ReadUvarint(z.r)
+	if err != nil {
+		return streamReadError(err)
+	}
+	if data_bytes == 0 {
+		if code_bytes != 0 {
+			return codecError("Read", ErrCorruptInput, "bad end of stream frame")
+		}
+		return io.EOF
+	}
+	if data_bytes > StreamChunkSize || code_bytes > streamCodeBufferSize {
+		return codecError("Read", ErrCorruptInput, "frame too large")
+	}
+	if _, err := io.ReadFull(z.r, z.codec.code_buffer[:code_bytes]); err != nil {
+		return streamReadError(err)
+	}
+
+	z.codec.StartDecoder()
+	for k := range z.data[:data_bytes] {
+		s := z.codec.Decode_AdaptiveDataModel(z.model)
+		if s > 0xFF {
+			z.codec.StopDecoder()
+			return codecError("Read", ErrCorruptInput, "symbol out of byte range")
+		}
+		z.data[k] = byte(s)
+	}
+	z.codec.StopDecoder()
+	if z.codec.corrupt {
+		return codecError("Read", ErrCorruptInput, "code leaves the decoder interval")
+	}
+	z.next = z.data[:data_bytes]
+	return nil
+}
+
+// Running out of input before the end of stream frame means the stream was
+// truncated.
+func streamReadError(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
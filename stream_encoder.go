@@ -0,0 +1,140 @@
+package FastAC
+
+import "io"
+
+// The buffered encoder keeps all its output in code_buffer because a carry
+// may ripple back through any number of 0xFF bytes. The stream encoder only
+// holds back the last byte that is not 0xFF (the one a carry would stop at)
+// and a count of the 0xFF bytes after it; everything before them is settled
+// and goes to the output. A carry settles everything held back, since the
+// coding interval can never again reach past it. Output is byte for byte the
+// same as the buffered encoder's.
+
+const codeSinkBufferSize = 4096
+
+type codeSink struct {
+	w         io.Writer
+	buffer    []byte // settled bytes not yet written to w
+	cache     byte   // last byte that is not 0xFF, if has_cache
+	has_cache bool
+	pending   uint64 // 0xFF bytes after cache
+	written   uint64 // bytes handed to w so far
+	err       error
+}
+
+func (s *codeSink) putByte(b byte) {
+	if b == 0xFF {
+		s.pending++
+		return
+	}
+	if s.has_cache {
+		s.emit(s.cache)
+	}
+	for ; s.pending > 0; s.pending-- {
+		s.emit(0xFF)
+	}
+	s.cache, s.has_cache = b, true
+}
+
+func (s *codeSink) carry() {
+	if s.has_cache {
+		s.emit(s.cache + 1)
+	}
+	for ; s.pending > 0; s.pending-- {
+		s.emit(0)
+	}
+	s.has_cache = false
+}
+
+func (s *codeSink) emit(b byte) {
+	s.buffer = append(s.buffer, b)
+	if len(s.buffer) == cap(s.buffer) {
+		s.flush()
+	}
+}
+
+func (s *codeSink) flush() {
+	if len(s.buffer) == 0 {
+		return
+	}
+	if s.err == nil {
+		_, s.err = s.w.Write(s.buffer)
+	}
+	s.written += uint64(len(s.buffer))
+	s.buffer = s.buffer[:0]
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+type emitFunc func(code []byte) error
+
+func (f emitFunc) Write(p []byte) (int, error) {
+	if err := f(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+// StartStreamEncoder starts an encoder that writes settled code bytes to w as
+// it goes instead of keeping them in the code buffer, so memory use does not
+// grow with the length of the stream. No code buffer is needed. StopEncoder
+// writes the remaining bytes and returns the total number written, modulo
+// 2^32.
+func (a *ArithmeticCodec) StartStreamEncoder(w io.Writer) {
+	mustSucceed(a.TryStartStreamEncoder(w))
+}
+
+func (a *ArithmeticCodec) TryStartStreamEncoder(w io.Writer) error {
+	if a.mode != Undefined {
+		return codecError("StartStreamEncoder", ErrWrongMode, "cannot start encoder")
+	}
+	a.mode = Encoder
+	a.base = 0
+	a.length = AC__MaxLength
+	a.sink = &codeSink{w: w, buffer: make([]byte, 0, codeSinkBufferSize)}
+	return nil
+}
+
+// StartCallbackEncoder is StartStreamEncoder with settled bytes handed to
+// emit, which must not keep the slice it is given.
+func (a *ArithmeticCodec) StartCallbackEncoder(emit func(code []byte) error) {
+	mustSucceed(a.TryStartStreamEncoder(emitFunc(emit)))
+}
+
+// FlushSettled writes every settled byte held by a stream encoder. Only the
+// last bytes a carry could still change stay behind.
+func (a *ArithmeticCodec) FlushSettled() error {
+	if a.mode != Encoder || a.sink == nil {
+		return codecError("FlushSettled", ErrWrongMode, "not a stream encoder")
+	}
+	a.sink.flush()
+	return a.sink.err
+}
+
+// - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - - -
+
+func (a *ArithmeticCodec) renormSinkInterval() {
+	for cont := true; cont; cont = a.length < AC__MinLength {
+		a.sink.putByte(byte(a.base >> 24))
+		a.base <<= 8
+		a.length <<= 8
+	}
+}
+
+func (a *ArithmeticCodec) stopSink() (uint32, error) {
+	s := a.sink
+	a.sink = nil
+	if s.has_cache {
+		s.emit(s.cache)
+	}
+	for ; s.pending > 0; s.pending-- {
+		s.emit(0xFF)
+	}
+	s.flush()
+	if s.err != nil {
+		return 0, codecError("StopEncoder", s.err, "cannot write code")
+	}
+	return uint32(s.written), nil
+}
@@ -0,0 +1,64 @@
+package FastAC
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Codes a mix of models, including long runs of all-ones bits that leave
+// 0xFF bytes pending until a carry or a normal byte settles them.
+func encodeStreamTestSymbols(codec *ArithmeticCodec) {
+	rg := initRandomGenerator(2022)
+	data_model := initAdaptiveDataModel(300)
+	bit_model := initAdaptiveBitModel()
+	for k := 0; k < 200000; k++ {
+		switch r := rg.Word(); {
+		case k%5000 < 400:
+			codec.PutBits(0xFFFF, 16)
+		case r&3 == 0:
+			codec.Encode_AdaptiveBitModel(r>>31, bit_model)
+		case r&3 == 1:
+			codec.PutBits(r>>16, 16)
+		default:
+			codec.Encode_AdaptiveDataModel((r>>8)%300, data_model)
+		}
+	}
+}
+
+func TestStreamEncoder_MatchesBuffered(t *testing.T) {
+	buffered := initArithmeticCodec(1<<20, nil)
+	buffered.StartEncoder()
+	encodeStreamTestSymbols(buffered)
+	want := buffered.code_buffer[:buffered.StopEncoder()]
+
+	var out bytes.Buffer
+	streaming := initArithmeticCodec(0, nil)
+	streaming.StartStreamEncoder(&out)
+	encodeStreamTestSymbols(streaming)
+	if streaming.sink.pending+uint64(len(streaming.sink.buffer)) > codeSinkBufferSize+8000 {
+		t.Errorf("stream encoder holds %d bytes", streaming.sink.pending+uint64(len(streaming.sink.buffer)))
+	}
+	if err := streaming.FlushSettled(); err != nil {
+		t.Fatalf("FlushSettled() error = %v", err)
+	}
+	code_bytes, err := streaming.TryStopEncoder()
+	if err != nil {
+		t.Fatalf("TryStopEncoder() error = %v", err)
+	}
+
+	if int(code_bytes) != len(want) || !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("stream encoder wrote %d bytes, buffered encoder %d; outputs differ", out.Len(), len(want))
+	}
+
+	var chunks [][]byte
+	callback := initArithmeticCodec(0, nil)
+	callback.StartCallbackEncoder(func(code []byte) error {
+		chunks = append(chunks, append([]byte(nil), code...))
+		return nil
+	})
+	encodeStreamTestSymbols(callback)
+	callback.StopEncoder()
+	if got := bytes.Join(chunks, nil); !bytes.Equal(got, want) {
+		t.Fatalf("callback encoder output differs from buffered encoder")
+	}
+}
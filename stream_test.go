@@ -0,0 +1,137 @@
+package FastAC
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func streamTestData(n int) []byte {
+	src := initRandomDataSource()
+	src.SetTruncatedGeometric(256, 5.0)
+	data := make([]byte, n)
+	for k := range data {
+		data[k] = byte(src.Data())
+	}
+	return data
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	data := streamTestData(3*StreamChunkSize + 1234)
+
+	var compressed bytes.Buffer
+	w, err := NewWriter(&compressed, nil)
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	// Odd-sized writes and a Flush in the middle exercise partial frames.
+	for k := 0; k < len(data); k += 10007 {
+		end := k + 10007
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write(data[k:end]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if k == 5*10007 {
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if compressed.Len() >= len(data) {
+		t.Errorf("compressed %d bytes into %d", len(data), compressed.Len())
+	}
+
+	r, err := NewReader(bytes.NewReader(compressed.Bytes()), nil)
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatalf("decoded data does not match input")
+	}
+
+	// A reset writer and reader must produce and accept an identical stream.
+	var again bytes.Buffer
+	w.Reset(&again)
+	w.Write(data)
+	w.Close()
+	r.Reset(&again)
+	decoded, err = io.ReadAll(r)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("round trip after Reset failed: %v", err)
+	}
+}
+
+func TestStream_Truncated(t *testing.T) {
+	var compressed bytes.Buffer
+	w, _ := NewWriter(&compressed, nil)
+	w.Write(streamTestData(1000))
+	w.Close()
+
+	r, _ := NewReader(bytes.NewReader(compressed.Bytes()[:compressed.Len()-2]), nil)
+	if _, err := io.ReadAll(r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAll() of truncated stream error = %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	model := initAdaptiveDataModel(16)
+	if _, err := NewWriter(io.Discard, model); !errors.Is(err, ErrInvalidAlphabet) {
+		t.Errorf("NewWriter() with 16 symbol model error = %v, want ErrInvalidAlphabet", err)
+	}
+}
+
+func TestStream_Corrupt(t *testing.T) {
+	// A frame of 100 bytes whose code is all ones takes the decoder past the
+	// end of its interval.
+	stream := append([]byte{100, 100}, bytes.Repeat([]byte{0xFF}, 100)...)
+	stream = append(stream, 0, 0)
+	r, _ := NewReader(bytes.NewReader(stream), nil)
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrCorruptInput) {
+		t.Errorf("ReadAll() of corrupt stream error = %v, want ErrCorruptInput", err)
+	}
+
+	// Damaged frames of a genuine stream decode to some data or fail.
+	var compressed bytes.Buffer
+	w, _ := NewWriter(&compressed, nil)
+	w.Write(streamTestData(5000))
+	w.Close()
+	rg := initRandomGenerator(2)
+	damaged := make([]byte, compressed.Len())
+	for trial := 0; trial < 100; trial++ {
+		copy(damaged, compressed.Bytes())
+		for k := 0; k < 4; k++ {
+			damaged[4+rg.Integer(uint32(len(damaged)-6))] = byte(rg.Integer(256))
+		}
+		r.Reset(bytes.NewReader(damaged))
+		if _, err := io.ReadAll(r); err != nil && !errors.Is(err, ErrCorruptInput) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("ReadAll() of damaged stream error = %v", err)
+		}
+	}
+}
+
+func TestStream_Large(t *testing.T) {
+	if testing.Short() {
+		t.Skip("codes more than 16 MB")
+	}
+	data := streamTestData(0x1000000 + StreamChunkSize/2)
+
+	var compressed bytes.Buffer
+	w, _ := NewWriter(&compressed, nil)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	r, _ := NewReader(&compressed, nil)
+	decoded, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(decoded, data) {
+		t.Fatalf("round trip of %d bytes failed: %v", len(data), err)
+	}
+}
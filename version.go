@@ -1,5 +1,7 @@
 package FastAC
 
+import "fmt"
+
 type Version int8
 
 const (
@@ -7,4 +9,18 @@ const (
 	Int3232_Sorted
 	Int3264
 	FloatingPoint
-)
\ No newline at end of file
+)
+
+func (v Version) String() string {
+	switch v {
+	case Int3232:
+		return "Int3232"
+	case Int3232_Sorted:
+		return "Int3232_Sorted"
+	case Int3264:
+		return "Int3264"
+	case FloatingPoint:
+		return "FloatingPoint"
+	}
+	return fmt.Sprintf("Version(%d)", int8(v))
+}